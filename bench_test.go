@@ -0,0 +1,262 @@
+// Copyright 2016 Russ Olsen. All Rights Reserved.
+//
+// This code is a Go port of the Java version created and maintained by Cognitect, therefore:
+//
+// Copyright 2014 Cognitect. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS-IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transit
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// writeCounter discards its input but counts the calls to Write,
+// each of which would be a system call on a file or a socket.
+type writeCounter struct {
+	writes int
+}
+
+func (wc *writeCounter) Write(p []byte) (int, error) {
+	wc.writes++
+	return len(p), nil
+}
+
+func smallMessage(i int) interface{} {
+	return map[Keyword]interface{}{
+		Keyword("id"):    i,
+		Keyword("name"):  "Alice \"the admin\" Smith",
+		Keyword("roles"): []interface{}{Keyword("admin"), Keyword("user")},
+		Keyword("score"): 98.5,
+		Keyword("ok"):    true,
+	}
+}
+
+func largeMessage() interface{} {
+	result := make([]interface{}, 1000)
+	for i := range result {
+		result[i] = smallMessage(i)
+	}
+	return result
+}
+
+func benchmarkEncode(b *testing.B, value interface{}, verbose bool) {
+	var wc writeCounter
+	e := NewEncoder(&wc, verbose)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if err := e.Encode(value); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.ReportMetric(float64(wc.writes)/float64(b.N), "writes/op")
+}
+
+func BenchmarkEncodeSmall(b *testing.B) {
+	benchmarkEncode(b, smallMessage(1), false)
+}
+
+func BenchmarkEncodeSmallVerbose(b *testing.B) {
+	benchmarkEncode(b, smallMessage(1), true)
+}
+
+func benchmarkEncodeStream(b *testing.B, autoFlush bool) {
+	var wc writeCounter
+	e := NewEncoder(&wc, false)
+	e.SetAutoFlush(autoFlush)
+	value := smallMessage(1)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if err := e.Encode(value); err != nil {
+			b.Fatal(err)
+		}
+	}
+	if err := e.Flush(); err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportMetric(float64(wc.writes)/float64(b.N), "writes/op")
+}
+
+func BenchmarkEncodeStreamAutoFlush(b *testing.B) {
+	benchmarkEncodeStream(b, true)
+}
+
+func BenchmarkEncodeStreamBuffered(b *testing.B) {
+	benchmarkEncodeStream(b, false)
+}
+
+func BenchmarkEncodeLarge(b *testing.B) {
+	benchmarkEncode(b, largeMessage(), false)
+}
+
+func BenchmarkEncodeStrings(b *testing.B) {
+	strings := make([]string, 100)
+	for i := range strings {
+		strings[i] = fmt.Sprintf("string number %d with \"quotes\", \\slashes\\ and é\n", i)
+	}
+	benchmarkEncode(b, strings, false)
+}
+
+func BenchmarkEncodeTyped(b *testing.B) {
+	scores := make(map[string]int, 100)
+	keywords := make([]Keyword, 100)
+	for i := range keywords {
+		scores[fmt.Sprintf("player%d", i)] = i
+		keywords[i] = Keyword(fmt.Sprintf("k%d", i))
+	}
+	benchmarkEncode(b, []interface{}{scores, keywords}, false)
+}
+
+func BenchmarkEncodeGeneric(b *testing.B) {
+	value := make([]interface{}, 100)
+	for i := range value {
+		value[i] = map[interface{}]interface{}{
+			"id":    i,
+			"tags":  []interface{}{"a", "b", int64(i)},
+			"ratio": float64(i) / 3,
+		}
+	}
+	benchmarkEncode(b, value, false)
+}
+
+func benchmarkDecode(b *testing.B, value interface{}, verbose bool, fromBytes bool) {
+	data, err := EncodeToString(value, verbose)
+	if err != nil {
+		b.Fatal(err)
+	}
+	input := []byte(data)
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(input)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if fromBytes {
+			_, err = DecodeBytes(input)
+		} else {
+			_, err = NewDecoder(bytes.NewReader(input)).Decode()
+		}
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecodeSmall(b *testing.B) {
+	benchmarkDecode(b, smallMessage(1), false, false)
+}
+
+func BenchmarkDecodeLarge(b *testing.B) {
+	benchmarkDecode(b, largeMessage(), false, false)
+}
+
+func BenchmarkDecodeLargeVerbose(b *testing.B) {
+	benchmarkDecode(b, largeMessage(), true, false)
+}
+
+func BenchmarkDecodeLargeBytes(b *testing.B) {
+	benchmarkDecode(b, largeMessage(), false, true)
+}
+
+// BenchmarkDecodeSmallStream reads many small values through one
+// Decoder, so that its read buffer is only allocated once.
+func BenchmarkDecodeSmallStream(b *testing.B) {
+	data, err := EncodeToString(smallMessage(1), false)
+	if err != nil {
+		b.Fatal(err)
+	}
+	input := []byte(strings.Repeat(data+"\n", b.N))
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data) + 1))
+	b.ResetTimer()
+
+	d := NewDecoder(bytes.NewReader(input))
+	for i := 0; i < b.N; i++ {
+		if _, err := d.Decode(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecodeSmallBytes(b *testing.B) {
+	benchmarkDecode(b, smallMessage(1), false, true)
+}
+
+func BenchmarkMarshalSmall(b *testing.B) {
+	value := smallMessage(1)
+	var buf []byte
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		var err error
+		buf, err = MarshalAppend(buf[:0], value)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchmarkParallel(b *testing.B, workers int) {
+	input := ndjson(b, largeMessage().([]interface{}))
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(input)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		for _, err := range NewParallelDecoder(bytes.NewReader(input), workers).All() {
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkDecodeLinesSequential(b *testing.B) {
+	input := ndjson(b, largeMessage().([]interface{}))
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(input)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		for _, err := range NewDecoder(bytes.NewReader(input)).All() {
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkDecodeLinesParallel1(b *testing.B) {
+	benchmarkParallel(b, 1)
+}
+
+func BenchmarkDecodeLinesParallel4(b *testing.B) {
+	benchmarkParallel(b, 4)
+}
@@ -21,33 +21,107 @@ package transit
 import (
 	"encoding/json"
 	"io"
+	"strconv"
 	"strings"
+	"sync"
 )
 
 type Handler func(Decoder, interface{}) (interface{}, error)
 
+// decodeOptions holds the settings of a Decoder that are not
+// handlers or limits.
+type decodeOptions struct {
+	defaultHandler  ReadHandler
+	continueOnError bool
+}
+
 type Decoder struct {
-	jsd      *json.Decoder
-	decoders map[string]Handler
+	scan     *scanner      // Reads the input, unless jsd is set.
+	jsd      *json.Decoder // Only set by NewJsonDecoder.
+	decoders map[string]ReadHandler
+	readers  *readers
 	cache    *RollingCache
+	limits   *limiter
+	options  *decodeOptions
+	path     *readPath
+	ctx      *ReadContext // Handed to each ReadHandler in turn.
+}
+
+// newDecoder returns a decoder with the standard handlers. Either
+// scan or jsd supplies the input.
+func newDecoder(scan *scanner, jsd *json.Decoder, limits *limiter) *Decoder {
+	decoders := make(map[string]ReadHandler)
+
+	d := Decoder{
+		scan:     scan,
+		jsd:      jsd,
+		decoders: decoders,
+		readers:  newReaders(),
+		cache:    NewRollingCache(),
+		limits:   limits,
+		options:  &decodeOptions{defaultHandler: PreserveUnknown},
+		path:     &readPath{},
+	}
+	d.ctx = &ReadContext{}
+	d.ctx.decoder = d
+	initHandlers(&d)
+
+	return &d
+}
+
+// fork returns a decoder for raw that shares the handlers, readers,
+// options and limits of d.
+func (d Decoder) fork(raw []byte) *Decoder {
+	f := d
+	f.jsd = nil
+	f.cache = NewRollingCache()
+	f.limits = &limiter{Limits: d.limits.Limits}
+	f.path = &readPath{}
+	f.scan = newBytesScanner(raw, f.limits)
+	f.ctx = &ReadContext{}
+	f.ctx.decoder = f
+	return &f
 }
 
-// NewDecoder returns a new Decoder, ready to read from r.
+// NewDecoder returns a new Decoder, ready to read from r. The decoder
+// reads the JSON itself, in a single pass, building transit values
+// as it goes.
 func NewDecoder(r io.Reader) *Decoder {
-	jsd := json.NewDecoder(r)
-	return NewJsonDecoder(jsd)
+	limits := &limiter{}
+	return newDecoder(newScanner(r, limits), nil, limits)
+}
+
+// newBytesDecoder returns a Decoder that reads straight from b.
+func newBytesDecoder(b []byte) *Decoder {
+	limits := &limiter{}
+	return newDecoder(newBytesScanner(b, limits), nil, limits)
+}
+
+// bytesDecoders holds the decoders used by DecodeBytes.
+var bytesDecoders = sync.Pool{
+	New: func() interface{} { return newBytesDecoder(nil) },
 }
 
-// NewDecoder returns a new Decoder, ready to read from jsr.
+// NewJsonDecoder returns a new Decoder, ready to read from jsd. The
+// JSON decoder builds a complete generic tree for every value before
+// it is turned into transit, so this is slower than NewDecoder.
 func NewJsonDecoder(jsd *json.Decoder) *Decoder {
 	jsd.UseNumber()
+	return newDecoder(nil, jsd, &limiter{})
+}
 
-	decoders := make(map[string]Handler)
-
-	d := Decoder{jsd: jsd, decoders: decoders, cache: NewRollingCache()}
-	initHandlers(&d)
+// SetLimits sets the resource limits that the decoder enforces
+// while reading. Once a limit is exceeded Decode returns a
+// *TransitError of kind KindLimit. MaxBytes counts every byte
+// the decoder reads from its input and is not enforced for
+// decoders created with NewJsonDecoder.
+func (d *Decoder) SetLimits(limits Limits) {
+	d.limits.Limits = limits
+}
 
-	return &d
+// Limits returns the resource limits currently in force.
+func (d Decoder) Limits() Limits {
+	return d.limits.Limits
 }
 
 func initHandlers(d *Decoder) {
@@ -73,16 +147,70 @@ func initHandlers(d *Decoder) {
 	d.AddHandler("list", DecodeList)
 	d.AddHandler("cmap", DecodeCMap)
 	d.AddHandler("ratio", DecodeRatio)
-	d.AddHandler("unknown", DecodeIdentity)
 
+	d.AddArrayReader("set", setReader{})
+	d.AddArrayReader("list", listReader{})
+	d.AddArrayReader("cmap", cmapReader{})
 }
 
 // AddHandler adds a new handler to the decoder, allowing you to extend the types it can handle.
+// The handler replaces any array or map reader added for the same tag.
 func (d Decoder) AddHandler(tag string, valueDecoder Handler) {
-	d.decoders[tag] = valueDecoder
+	d.AddReadHandler(tag, valueDecoder)
 }
 
-func (d Decoder) parseString(s string) (interface{}, error) {
+// SetDefaultReadHandler sets the handler for values whose tag has no
+// handler of its own. Use PreserveUnknown, the default, to keep them
+// as TaggedValues or RejectUnknown to fail with an error of kind
+// KindUnknownType. Any other ReadHandler works too, ctx.Tag() tells
+// it the tag. A nil handler restores the default.
+func (d Decoder) SetDefaultReadHandler(h ReadHandler) {
+	if h == nil {
+		h = PreserveUnknown
+	}
+	d.options.defaultHandler = h
+}
+
+// AddReadHandler is like AddHandler, but takes a ReadHandler.
+func (d Decoder) AddReadHandler(tag string, h ReadHandler) {
+	d.decoders[tag] = h
+	delete(d.readers.tagArrays, tag)
+	delete(d.readers.tagMaps, tag)
+}
+
+// SetArrayReader sets the reader that builds plain arrays. A nil
+// reader restores the default, which builds []interface{} values.
+// Like the other collection readers, it is only used by decoders
+// that read their own input, not those created with NewJsonDecoder.
+func (d Decoder) SetArrayReader(r ArrayReader) {
+	d.readers.array = r
+}
+
+// SetMapReader sets the reader that builds plain maps. A nil reader
+// restores the default, which builds map[interface{}]interface{}
+// values.
+func (d Decoder) SetMapReader(r MapReader) {
+	if r == nil {
+		r = genericMapReader{}
+	}
+	d.readers.mapReader = r
+}
+
+// AddArrayReader arranges for the array in a value tagged with tag
+// to be built by r. Whatever r completes is the decoded value, the
+// tag's handler is not called.
+func (d Decoder) AddArrayReader(tag string, r ArrayReader) {
+	d.readers.tagArrays[tag] = r
+}
+
+// AddMapReader arranges for the map in a value tagged with tag to
+// be built by r. Whatever r completes is the decoded value, the
+// tag's handler is not called.
+func (d Decoder) AddMapReader(tag string, r MapReader) {
+	d.readers.tagMaps[tag] = r
+}
+
+func (d Decoder) parseString(s string, asKey bool) (interface{}, error) {
 
 	if d.cache.HasKey(s) {
 		return d.Parse(d.cache.Read(s), false)
@@ -90,11 +218,14 @@ func (d Decoder) parseString(s string) (interface{}, error) {
 	} else if !strings.HasPrefix(s, start) {
 		return s, nil
 
+	} else if len(s) < 2 {
+		return nil, newSyntaxError("Escape character with nothing after it", s, nil)
+
 	} else if strings.HasPrefix(s, startTag) {
 		return TagId(s[2:]), nil
 
 	} else if vd := d.decoders[s[1:2]]; vd != nil {
-		return vd(d, s[2:])
+		return d.callHandler(s[1:2], vd, s[2:], false, asKey)
 
 	} else if strings.HasPrefix(s, escapeTag) ||
 		strings.HasPrefix(s, escapeSub) ||
@@ -102,8 +233,7 @@ func (d Decoder) parseString(s string) (interface{}, error) {
 		return s[1:], nil
 
 	} else {
-		tv := TaggedValue{TagId(s[1:2]), s[2:]}
-		return d.decoders["unknown"](d, tv)
+		return d.callHandler(s[1:2], d.options.defaultHandler, s[2:], false, asKey)
 	}
 }
 
@@ -113,18 +243,16 @@ func (d Decoder) parseSingleEntryMap(m map[string]interface{}) (interface{}, err
 	for k, v := range m {
 		key, err := d.Parse(k, true)
 		if err != nil {
-			return nil, err
+			return nil, withPath(err, k)
 		}
 
 		value, err := d.Parse(v, true)
 		if err != nil {
-			return nil, err
+			return nil, withPath(err, key)
 		}
 
 		if tag, isTag := key.(TagId); isTag {
-			tv := TaggedValue{Tag: tag, Value: value}
-			valueDecoder := d.DecoderFor(tag)
-			return valueDecoder(d, tv)
+			return d.callTagHandler(tag, value)
 		} else {
 			return map[interface{}]interface{}{key: value}, nil
 		}
@@ -139,12 +267,12 @@ func (d Decoder) parseMultiEntryMap(m map[string]interface{}) (interface{}, erro
 	for k, v := range m {
 		key, err := d.Parse(k, true)
 		if err != nil {
-			return nil, err
+			return nil, withPath(err, k)
 		}
 
 		value, err := d.Parse(v, false)
 		if err != nil {
-			return nil, err
+			return nil, withPath(err, key)
 		}
 
 		result[key] = value
@@ -168,7 +296,7 @@ func (d Decoder) parseNormalArray(x []interface{}) (interface{}, error) {
 		var err error
 		result[i], err = d.Parse(v, false)
 		if err != nil {
-			return nil, err
+			return nil, withPath(err, i)
 		}
 	}
 
@@ -183,12 +311,12 @@ func (d Decoder) parseCMap(x []interface{}) (interface{}, error) {
 	for i := 1; i < l; i += 2 {
 		key, err := d.Parse(x[i], true)
 		if err != nil {
-			return nil, err
+			return nil, withPath(err, i)
 		}
 
 		value, err := d.Parse(x[i+1], false)
 		if err != nil {
-			return nil, err
+			return nil, withPath(err, key)
 		}
 		result.Append(key, value)
 	}
@@ -204,12 +332,12 @@ func (d Decoder) parseArrayMap(x []interface{}) (interface{}, error) {
 	for i := 1; i < l; i += 2 {
 		key, err := d.Parse(x[i], true)
 		if err != nil {
-			return nil, err
+			return nil, withPath(err, i)
 		}
 
 		value, err := d.Parse(x[i+1], false)
 		if err != nil {
-			return nil, err
+			return nil, withPath(err, key)
 		}
 		result[key] = value
 	}
@@ -218,13 +346,34 @@ func (d Decoder) parseArrayMap(x []interface{}) (interface{}, error) {
 }
 
 func (d Decoder) DecoderFor(tagid TagId) Handler {
-	key := string(tagid)
+	h := d.readHandlerFor(string(tagid))
+
+	if handler, ok := h.(Handler); ok {
+		return handler
+	}
+
+	return func(d Decoder, x interface{}) (interface{}, error) {
+		ctx := &ReadContext{decoder: d, tag: string(tagid)}
+		if tv, ok := x.(TaggedValue); ok {
+			x = tv.Value
+		}
+		return h.FromRep(ctx, x)
+	}
+}
 
-	handler := d.decoders[key]
-	if handler == nil {
-		handler = d.decoders["unknown"]
+// readHandlerFor returns the handler for tag, falling back to the
+// default read handler.
+func (d Decoder) readHandlerFor(tag string) ReadHandler {
+	if h := d.decoders[tag]; h != nil {
+		return h
 	}
-	return handler
+	return d.options.defaultHandler
+}
+
+// isLazy reports whether h wants the representation undecoded.
+func isLazy(h ReadHandler) bool {
+	lh, ok := h.(LazyReadHandler)
+	return ok && lh.Lazy()
 }
 
 func (d Decoder) parseArray(x []interface{}) (interface{}, error) {
@@ -235,7 +384,7 @@ func (d Decoder) parseArray(x []interface{}) (interface{}, error) {
 	e0, err := d.Parse(x[0], false)
 
 	if err != nil {
-		return nil, err
+		return nil, withPath(err, 0)
 	}
 
 	if e0 == mapAsArray {
@@ -245,13 +394,15 @@ func (d Decoder) parseArray(x []interface{}) (interface{}, error) {
 	if tagId, isTag := e0.(TagId); isTag {
 		var value interface{}
 
+		if len(x) != 2 {
+			return nil, newSyntaxError("Tagged value must have exactly one value", x, nil)
+		}
+
 		if value, err = d.Parse(x[1], false); err != nil {
 			return nil, err
 		}
 
-		tv := TaggedValue{Tag: tagId, Value: value}
-		valueDecoder := d.DecoderFor(tagId)
-		return valueDecoder(d, tv)
+		return d.callTagHandler(tagId, value)
 	}
 
 	return d.parseNormalArray(x)
@@ -261,6 +412,10 @@ func (d Decoder) parseNumber(x json.Number) (interface{}, error) {
 	var s = x.String()
 	var err error
 
+	if err = d.limits.checkDigits(len(s)); err != nil {
+		return nil, err
+	}
+
 	var result interface{}
 
 	if strings.ContainsAny(s, ".Ee") {
@@ -269,14 +424,19 @@ func (d Decoder) parseNumber(x json.Number) (interface{}, error) {
 		result, err = x.Int64()
 	}
 
-	return result, err
+	if err != nil {
+		return nil, newSyntaxError("Bad number", s, err)
+	}
+	return result, nil
 }
 
+// Parse turns a value decoded by encoding/json into transit. It is
+// used by decoders created with NewJsonDecoder.
 func (d Decoder) Parse(x interface{}, asKey bool) (interface{}, error) {
 
 	switch v := x.(type) {
 	default:
-		return nil, &TransitError{Message: "Unexpected type"}
+		return nil, &TransitError{Kind: KindUnknownType, Message: "Unexpected type", Source: x}
 	case nil:
 		return v, nil
 
@@ -287,7 +447,11 @@ func (d Decoder) Parse(x interface{}, asKey bool) (interface{}, error) {
 		return d.parseNumber(v)
 
 	case string:
-		result, err := d.parseString(v)
+		if err := d.limits.checkLength(len(v)); err != nil {
+			return nil, err
+		}
+
+		result, err := d.parseString(v, asKey)
 
 		if err == nil && d.cache.IsCacheable(v, asKey) {
 			d.cache.Write(v)
@@ -295,28 +459,549 @@ func (d Decoder) Parse(x interface{}, asKey bool) (interface{}, error) {
 		return result, err
 
 	case map[string]interface{}:
+		if err := d.limits.enter(); err != nil {
+			return nil, err
+		}
+		defer d.limits.leave()
+
+		if err := d.limits.checkElements(len(v)); err != nil {
+			return nil, err
+		}
 		return d.parseMap(v)
 
 	case []interface{}:
+		if err := d.limits.enter(); err != nil {
+			return nil, err
+		}
+		defer d.limits.leave()
+
+		if err := d.limits.checkElements(len(v)); err != nil {
+			return nil, err
+		}
 		return d.parseArray(v)
 	}
 }
 
-// Decode decodes the next Transit value from the stream.
+// Decode decodes the next Transit value from the stream. At the end
+// of the stream it returns io.EOF.
 func (d Decoder) Decode() (interface{}, error) {
-	var jsonObject interface{}
-	var err = d.jsd.Decode(&jsonObject)
+	// Cache codes never refer back to an earlier top level value.
+	d.cache.Clear()
+	d.limits.depth = 0
+	d.path.entries = d.path.entries[:0]
+
+	if d.jsd != nil {
+		var jsonObject interface{}
+		if err := d.jsd.Decode(&jsonObject); err != nil {
+			return nil, jsonError(err)
+		}
+		return d.Parse(jsonObject, false)
+	}
+
+	if err := d.scan.checkSize(); err != nil {
+		return nil, err
+	}
+	if _, err := d.scan.peek(); err != nil {
+		return nil, err
+	}
+	d.scan.newline = false
+	value, err := d.readValue(false)
+	if err != nil {
+		return nil, d.scan.locate(err)
+	}
+	return value, nil
+}
 
+// readValue reads the next value from the input.
+func (d Decoder) readValue(asKey bool) (interface{}, error) {
+	c, err := d.scan.next()
 	if err != nil {
 		return nil, err
-	} else {
-		return d.Parse(jsonObject, false)
 	}
+
+	switch c {
+	case '"':
+		raw, err := d.scan.readString()
+		if err != nil {
+			return nil, err
+		}
+		return d.readString(raw, asKey)
+	case '[':
+		value, _, err := d.readArray(nil, nil)
+		return value, err
+	case '{':
+		value, _, err := d.readMap(nil)
+		return value, err
+	case 't':
+		return true, d.scan.readLiteral("true")
+	case 'f':
+		return false, d.scan.readLiteral("false")
+	case 'n':
+		return nil, d.scan.readLiteral("null")
+	}
+
+	if c == '-' || (c >= '0' && c <= '9') {
+		return d.readNumber()
+	}
+	return nil, d.scan.syntaxError("Unexpected character '"+string(c)+"'", nil)
+}
+
+// readString turns the raw contents of a JSON string into a transit
+// value, expanding cache codes and recording cacheable strings.
+func (d Decoder) readString(raw []byte, asKey bool) (interface{}, error) {
+	if len(raw) > 1 && raw[0] == '^' {
+		if cached, present := d.cache.lookup(raw); present {
+			return d.parseString(cached, asKey)
+		}
+	}
+
+	s := string(raw)
+	result, err := d.parseString(s, asKey)
+
+	if err == nil && d.cache.IsCacheable(s, asKey) {
+		d.cache.Write(s)
+	}
+	return result, err
+}
+
+func (d Decoder) readNumber() (interface{}, error) {
+	b, integer, err := d.scan.readNumber()
+	if err != nil {
+		return nil, err
+	}
+
+	if integer {
+		if i, ok := parseSmallInt(b); ok {
+			return i, nil
+		}
+		i, err := strconv.ParseInt(string(b), 10, 64)
+		if err != nil {
+			return nil, newSyntaxError("Bad number", string(b), err)
+		}
+		return i, nil
+	}
+
+	f, err := strconv.ParseFloat(string(b), 64)
+	if err != nil {
+		return nil, newSyntaxError("Bad number", string(b), err)
+	}
+	return f, nil
+}
+
+// parseSmallInt parses integers that are too short to overflow
+// without going through strconv.
+func parseSmallInt(b []byte) (int64, bool) {
+	digits := b
+	if len(b) > 0 && b[0] == '-' {
+		digits = b[1:]
+	}
+	if len(digits) == 0 || len(digits) > 18 {
+		return 0, false
+	}
+
+	var i int64
+	for _, c := range digits {
+		i = i*10 + int64(c-'0')
+	}
+	if len(digits) < len(b) {
+		i = -i
+	}
+	return i, true
+}
+
+// readArray reads a JSON array, which may turn out to be a tagged
+// value or a map in array form. Arrays are built by ar and maps by
+// mr, or by the decoder's own readers when they are nil. The boolean
+// result reports whether ar or mr built the value.
+func (d Decoder) readArray(ar ArrayReader, mr MapReader) (interface{}, bool, error) {
+	if err := d.limits.enter(); err != nil {
+		return nil, false, err
+	}
+	defer d.limits.leave()
+
+	d.path.push()
+	defer d.path.pop()
+
+	d.scan.pos++
+
+	built := ar != nil
+	if ar == nil {
+		ar = d.readers.array
+	}
+
+	c, err := d.scan.next()
+	if err != nil {
+		return nil, false, err
+	}
+	if c == ']' {
+		d.scan.pos++
+		if ar == nil {
+			return []interface{}{}, false, nil
+		}
+		value, err := ar.Complete(ar.Init())
+		if err != nil {
+			return nil, false, readerError(err)
+		}
+		return value, built, nil
+	}
+
+	var first interface{}
+
+	if c == '"' {
+		raw, err := d.scan.readString()
+		if err != nil {
+			return nil, false, withPath(err, 0)
+		}
+		if string(raw) == mapAsArray {
+			value, err := d.readArrayMap(mr)
+			return value, mr != nil, err
+		}
+		first, err = d.readString(raw, false)
+		if err != nil {
+			return nil, false, withPath(err, 0)
+		}
+	} else if first, err = d.readValue(false); err != nil {
+		return nil, false, withPath(err, 0)
+	}
+
+	if tagId, isTag := first.(TagId); isTag {
+		d.path.hide()
+		value, err := d.readTagged(tagId, ']')
+		return value, false, err
+	}
+
+	if ar == nil {
+		value, err := d.readGenericArray(first)
+		return value, false, err
+	}
+
+	a, err := ar.Add(ar.Init(), first)
+	if err != nil {
+		return nil, false, withPath(readerError(err), 0)
+	}
+
+	for n := 1; ; n++ {
+		more, err := d.readSeparator(']')
+		if err != nil {
+			return nil, false, err
+		}
+		if !more {
+			value, err := ar.Complete(a)
+			if err != nil {
+				return nil, false, readerError(err)
+			}
+			return value, built, nil
+		}
+
+		if err := d.limits.checkElements(n + 1); err != nil {
+			return nil, false, err
+		}
+
+		d.path.setIndex(n)
+		item, err := d.readValue(false)
+		if err != nil {
+			return nil, false, withPath(err, n)
+		}
+
+		if a, err = ar.Add(a, item); err != nil {
+			return nil, false, withPath(readerError(err), n)
+		}
+	}
+}
+
+// readGenericArray reads the rest of an array into a []interface{}.
+func (d Decoder) readGenericArray(first interface{}) (interface{}, error) {
+	result := []interface{}{first}
+
+	for {
+		more, err := d.readSeparator(']')
+		if err != nil {
+			return nil, err
+		}
+		if !more {
+			return result, nil
+		}
+
+		if err := d.limits.checkElements(len(result) + 1); err != nil {
+			return nil, err
+		}
+
+		d.path.setIndex(len(result))
+		value, err := d.readValue(false)
+		if err != nil {
+			return nil, withPath(err, len(result))
+		}
+		result = append(result, value)
+	}
+}
+
+// readArrayMap reads the rest of a map in array form, just after the
+// "^ " marker.
+func (d Decoder) readArrayMap(mr MapReader) (interface{}, error) {
+	if mr == nil {
+		mr = d.readers.mapReader
+	}
+
+	m := mr.Init()
+
+	for i := 1; ; i += 2 {
+		more, err := d.readSeparator(']')
+		if err != nil {
+			return nil, err
+		}
+		if !more {
+			return d.completeMap(mr, m)
+		}
+
+		d.path.readingKey()
+		key, err := d.readValue(true)
+		if err != nil {
+			return nil, withPath(err, i)
+		}
+		d.path.setKey(key)
+
+		if err := d.scan.expect(','); err != nil {
+			return nil, err
+		}
+
+		if m, err = d.addEntry(mr, m, key, i/2+1); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// readMap reads a JSON object, which is either a tagged value or a
+// map. Maps are built by mr, or by the decoder's own map reader when
+// mr is nil. The boolean result reports whether mr built the value.
+func (d Decoder) readMap(mr MapReader) (interface{}, bool, error) {
+	if err := d.limits.enter(); err != nil {
+		return nil, false, err
+	}
+	defer d.limits.leave()
+
+	d.path.push()
+	defer d.path.pop()
+
+	d.scan.pos++
+
+	built := mr != nil
+	if mr == nil {
+		mr = d.readers.mapReader
+	}
+
+	var m interface{}
+
+	for i := 0; ; i++ {
+		if i > 0 {
+			more, err := d.readSeparator('}')
+			if err != nil {
+				return nil, false, err
+			}
+			if !more {
+				value, err := d.completeMap(mr, m)
+				return value, built, err
+			}
+		} else if c, err := d.scan.next(); err != nil {
+			return nil, false, err
+		} else if c == '}' {
+			d.scan.pos++
+			value, err := d.completeMap(mr, mr.Init())
+			return value, built, err
+		}
+
+		if c, err := d.scan.next(); err != nil {
+			return nil, false, err
+		} else if c != '"' {
+			return nil, false, d.scan.syntaxError("Map key must be a string", nil)
+		}
+
+		raw, err := d.scan.readString()
+		if err != nil {
+			return nil, false, err
+		}
+		path := string(raw)
+
+		d.path.readingKey()
+		key, err := d.readString(raw, true)
+		if err != nil {
+			return nil, false, withPath(err, path)
+		}
+
+		if err := d.scan.expect(':'); err != nil {
+			return nil, false, err
+		}
+
+		if i == 0 {
+			if tagId, isTag := key.(TagId); isTag {
+				d.path.hide()
+				value, err := d.readTagged(tagId, '}')
+				return value, false, err
+			}
+			m = mr.Init()
+		}
+		d.path.setKey(key)
+
+		if m, err = d.addEntry(mr, m, key, i+1); err != nil {
+			return nil, false, err
+		}
+	}
+}
+
+// readTagged reads the value of a tagged value followed by end, the
+// close of the array or map holding it. Arrays and maps that the
+// tag's own readers build are finished values, anything else goes to
+// the tag's handler.
+func (d Decoder) readTagged(tagId TagId, end byte) (interface{}, error) {
+	if end == ']' {
+		if err := d.scan.expect(','); err != nil {
+			return nil, err
+		}
+	}
+
+	c, err := d.scan.next()
+	if err != nil {
+		return nil, err
+	}
+
+	var value interface{}
+	var built bool
+
+	tag := string(tagId)
+	ar, mr := d.readers.tagArrays[tag], d.readers.tagMaps[tag]
+	h := d.readHandlerFor(tag)
+
+	switch {
+	case c == '[' && (ar != nil || mr != nil):
+		value, built, err = d.readArray(ar, mr)
+	case c == '{' && mr != nil:
+		value, built, err = d.readMap(mr)
+	case isLazy(h):
+		var raw []byte
+		raw, err = d.captureValue(nil, false)
+		value = RawValue(raw)
+	default:
+		value, err = d.readValue(false)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if c, err := d.scan.next(); err != nil {
+		return nil, err
+	} else if c != end {
+		return nil, d.scan.syntaxError("Tagged value must have exactly one value", nil)
+	}
+	d.scan.pos++
+
+	if built {
+		return value, nil
+	}
+	return d.callHandler(tag, h, value, true, d.path.inKey())
+}
+
+// addEntry reads the value for key and adds the n'th entry to m.
+func (d Decoder) addEntry(mr MapReader, m interface{}, key interface{}, n int) (interface{}, error) {
+	if err := d.limits.checkElements(n); err != nil {
+		return nil, err
+	}
+
+	value, err := d.readValue(false)
+	if err != nil {
+		return nil, withPath(err, key)
+	}
+
+	if m, err = mr.Add(m, key, value); err != nil {
+		return nil, withPath(readerError(err), key)
+	}
+	return m, nil
+}
+
+func (d Decoder) completeMap(mr MapReader, m interface{}) (interface{}, error) {
+	value, err := mr.Complete(m)
+	if err != nil {
+		return nil, readerError(err)
+	}
+	return value, nil
+}
+
+// readSeparator reads the separator after an element of an array or
+// map. It returns false once it reaches the closing bracket.
+func (d Decoder) readSeparator(end byte) (bool, error) {
+	c, err := d.scan.next()
+	if err != nil {
+		return false, err
+	}
+
+	switch c {
+	case ',':
+		d.scan.pos++
+		return true, nil
+	case end:
+		d.scan.pos++
+		return false, nil
+	}
+	return false, d.scan.syntaxError("Expected ',' or '"+string(end)+"' but found '"+string(c)+"'", nil)
+}
+
+// callTagHandler calls the handler for a composite value that has
+// already been decoded, as it is with NewJsonDecoder.
+func (d Decoder) callTagHandler(tagId TagId, value interface{}) (interface{}, error) {
+	h := d.readHandlerFor(string(tagId))
+	if isLazy(h) {
+		msg := "Lazy read handlers need a decoder created with NewDecoder"
+		return nil, &TransitError{Kind: KindHandler, Message: msg, Source: string(tagId)}
+	}
+	return d.callHandler(string(tagId), h, value, true, false)
+}
+
+// callHandler calls the handler for tag, making sure that any
+// error it returns is a TransitError. The tagged flag says that rep
+// belongs to a composite value.
+func (d Decoder) callHandler(tag string, h ReadHandler, rep interface{}, tagged, asKey bool) (interface{}, error) {
+	ctx := d.ctx
+	savedTag, savedTagged, savedAsKey := ctx.tag, ctx.tagged, ctx.asKey
+	ctx.tag, ctx.tagged, ctx.asKey = tag, tagged, asKey
+
+	result, err := h.FromRep(ctx, rep)
+
+	ctx.tag, ctx.tagged, ctx.asKey = savedTag, savedTagged, savedAsKey
+
+	if err != nil {
+		return nil, handlerError(tag, err)
+	}
+	return result, nil
+}
+
+// jsonError turns the errors reported by the JSON decoder into
+// syntax errors. Plain I/O errors, including io.EOF, are passed
+// along unchanged.
+func jsonError(err error) error {
+	switch err.(type) {
+	case *json.SyntaxError, *json.UnmarshalTypeError:
+		return newSyntaxError("Malformed JSON", nil, err)
+	}
+	if err == io.ErrUnexpectedEOF {
+		return newSyntaxError("Unexpected end of input", nil, err)
+	}
+	return err
 }
 
 // DecodeFromString is a handly function that decodes Transit data held in a string.
 func DecodeFromString(s string) (interface{}, error) {
-	reader := strings.NewReader(s)
-	decoder := NewDecoder(reader)
-	return decoder.Decode()
+	return DecodeBytes([]byte(s))
+}
+
+// DecodeBytes decodes the first Transit value held in b. The decoder
+// reads straight from the slice, with no io.Reader in between, and
+// comes from a pool, so that decoding many small values is cheap.
+func DecodeBytes(b []byte) (interface{}, error) {
+	d := bytesDecoders.Get().(*Decoder)
+	d.scan.resetBytes(b)
+
+	value, err := d.Decode()
+
+	d.scan.resetBytes(nil)
+	bytesDecoders.Put(d)
+
+	return value, err
 }
@@ -0,0 +1,143 @@
+// Copyright 2016 Russ Olsen. All Rights Reserved.
+//
+// This code is a Go port of the Java version created and maintained by Cognitect, therefore:
+//
+// Copyright 2014 Cognitect. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS-IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transit
+
+import (
+	"reflect"
+)
+
+// DefaultMaxDepth is the deepest an Encoder will nest collections and
+// pointers unless told otherwise with SetMaxDepth.
+const DefaultMaxDepth = 10000
+
+// cycleCheckDepth is the depth past which the encoder starts looking
+// for cycles. Checking costs a map lookup per value, so like
+// encoding/json we only pay for it once the nesting gets suspicious.
+// Any cycle goes past this depth soon enough.
+const cycleCheckDepth = 100
+
+// reference identifies a pointer, map or slice. Slices that share a
+// backing array but differ in length are different values.
+type reference struct {
+	t   reflect.Type
+	ptr uintptr
+	len int
+}
+
+// encodeDepth keeps track of how deeply nested the value being
+// encoded is and, once it is deep, of the references being encoded.
+type encodeDepth struct {
+	max   int
+	depth int
+	seen  map[reference]struct{}
+}
+
+func newEncodeDepth() *encodeDepth {
+	return &encodeDepth{max: DefaultMaxDepth}
+}
+
+func (d *encodeDepth) reset() {
+	d.depth = 0
+	clear(d.seen)
+}
+
+// checking reports whether the next enter will look for cycles.
+func (d *encodeDepth) checking() bool {
+	return d.depth >= cycleCheckDepth
+}
+
+// enter records that the encoder is going into v.
+func (d *encodeDepth) enter(v reflect.Value) error {
+	if d.max > 0 && d.depth >= d.max {
+		return newLimitError("Nesting depth", int64(d.max), nil)
+	}
+
+	if d.checking() {
+		if r, ok := referenceTo(v); ok {
+			if _, found := d.seen[r]; found {
+				return &TransitError{Kind: KindCycle, Message: "Cycle through " + r.t.String(), Source: r.t}
+			}
+			if d.seen == nil {
+				d.seen = make(map[reference]struct{})
+			}
+			d.seen[r] = struct{}{}
+		}
+	}
+
+	d.depth++
+	return nil
+}
+
+// leave undoes a successful enter of v.
+func (d *encodeDepth) leave(v reflect.Value) {
+	d.depth--
+	if d.checking() {
+		if r, ok := referenceTo(v); ok {
+			delete(d.seen, r)
+		}
+	}
+}
+
+func referenceTo(v reflect.Value) (reference, bool) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Map:
+		if v.IsNil() {
+			return reference{}, false
+		}
+		return reference{v.Type(), v.Pointer(), 0}, true
+	case reflect.Slice:
+		if v.Len() == 0 {
+			return reference{}, false
+		}
+		return reference{v.Type(), v.Pointer(), v.Len()}, true
+	}
+	return reference{}, false
+}
+
+// nests reports whether values of type t can hold other values, and
+// so need their depth tracked.
+func nests(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Array, reflect.Struct, reflect.Interface:
+		return true
+	}
+	return false
+}
+
+// guard wraps encode so that it keeps track of the depth.
+func guard(encode encodeFunc) encodeFunc {
+	return func(e Encoder, v reflect.Value, asKey bool) error {
+		if err := e.depth.enter(v); err != nil {
+			return err
+		}
+		err := encode(e, v, asKey)
+		e.depth.leave(v)
+		return err
+	}
+}
+
+// enterGeneric is enter for the fast paths in EncodeInterface, which
+// only build a reflect.Value for x once the encoder looks for cycles.
+func enterGeneric[T any](e Encoder, x T) (reflect.Value, error) {
+	var v reflect.Value
+	if e.depth.checking() {
+		v = reflect.ValueOf(x)
+	}
+	return v, e.depth.enter(v)
+}
@@ -20,11 +20,15 @@ package transit
 
 import (
 	"encoding/json"
-	"fmt"
 	"io"
-	"strings"
+	"math"
+	"strconv"
+	"unicode/utf8"
 )
 
+// DataEmitter writes the pieces of transit output. Emitters that
+// buffer their output, as JsonEmitter does, also have a Flush() error
+// method.
 type DataEmitter interface {
 	Emit(s string) error
 	EmitString(s string, cacheable bool) error
@@ -43,30 +47,118 @@ type DataEmitter interface {
 	EmitEndMap() error
 }
 
+// bufferSize is the amount of output the JsonEmitter collects before
+// it writes to the underlying writer.
+const bufferSize = 4096
+
+// JsonEmitter emits transit JSON. Output is collected in an internal
+// buffer and only handed to the underlying writer when the buffer
+// fills up or when Flush is called.
 type JsonEmitter struct {
-	writer io.Writer
-	cache  Cache
+	out   *output
+	cache Cache
 }
 
 func NewJsonEmitter(w io.Writer, cache Cache) *JsonEmitter {
-	return &JsonEmitter{writer: w, cache: cache}
+	out := &output{w: w, buf: make([]byte, 0, bufferSize)}
+	return &JsonEmitter{out: out, cache: cache}
+}
+
+// Flush writes any buffered output to the underlying writer.
+func (je JsonEmitter) Flush() error {
+	return je.out.flush()
+}
+
+// Reset discards any buffered output and any earlier write error
+// and directs future output to w.
+func (je JsonEmitter) Reset(w io.Writer) {
+	je.out.w = w
+	je.out.buf = je.out.buf[:0]
+	je.out.n = 0
+	je.out.err = nil
+}
+
+// BytesWritten returns the number of bytes that have been
+// successfully written to the underlying writer so far.
+func (je JsonEmitter) BytesWritten() int64 {
+	return je.out.n
+}
+
+// output is the buffer behind a JsonEmitter. It keeps track of the
+// bytes written through it. Once the underlying writer fails every
+// later write fails with a copy of the same error, so that encoding
+// stops at the first failure.
+type output struct {
+	w   io.Writer
+	buf []byte
+	n   int64
+	err *TransitError
+}
+
+// failed returns a copy of the write error, so that callers can add to
+// it without changing the one kept here.
+func (o *output) failed() error {
+	c := *o.err
+	return &c
+}
+
+// appendTo points the output at dst and detaches it from its writer,
+// so that everything written is simply appended to dst.
+func (o *output) appendTo(dst []byte) {
+	o.w = nil
+	o.buf = dst
+	o.n = 0
+	o.err = nil
+}
+
+// done is called after something has been appended to the buffer.
+// It writes the buffer out if it is full.
+func (o *output) done() error {
+	if o.err != nil {
+		return o.failed()
+	}
+	if len(o.buf) >= bufferSize && o.w != nil {
+		return o.flush()
+	}
+	return nil
+}
+
+func (o *output) flush() error {
+	if o.err != nil {
+		return o.failed()
+	}
+	if len(o.buf) == 0 || o.w == nil {
+		return nil
+	}
+	n, err := o.w.Write(o.buf)
+	o.n += int64(n)
+	if err == nil && n < len(o.buf) {
+		err = io.ErrShortWrite
+	}
+	o.buf = o.buf[:0]
+	if err != nil {
+		o.err = &TransitError{Kind: KindWrite, Message: "Write failed", Written: o.n, Err: err}
+		return o.failed()
+	}
+	return nil
 }
 
 // Emit the string unaltered and without quotes. This is the lowest level emitter.
 
 func (je JsonEmitter) Emit(s string) error {
-	_, err := je.writer.Write([]byte(s))
-	return err
+	je.out.buf = append(je.out.buf, s...)
+	return je.out.done()
 }
 
 // EmitBase emits the basic value supplied, encoding it as JSON.
 
 func (je JsonEmitter) EmitBase(x interface{}) error {
 	bytes, err := json.Marshal(x)
-	if err == nil {
-		_, err = je.writer.Write(bytes)
+	if err != nil {
+		return err
 	}
-	return err
+	je.out.buf = append(je.out.buf, bytes...)
+	return je.out.done()
 }
 
 // EmitsTag emits a transit #tag. The string supplied should not include the '#'.
@@ -79,36 +171,100 @@ func (je JsonEmitter) EmitString(s string, cacheable bool) error {
 	if je.cache.IsCacheable(s, cacheable) {
 		s = je.cache.Write(s)
 	}
-	return je.EmitBase(s)
+	je.out.buf = appendQuoted(je.out.buf, s)
+	return je.out.done()
+}
+
+const hex = "0123456789abcdef"
+
+// appendQuoted appends s to buf as a quoted JSON string. Invalid
+// UTF-8 is replaced with U+FFFD, just as encoding/json does.
+func appendQuoted(buf []byte, s string) []byte {
+	buf = append(buf, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if b := s[i]; b < utf8.RuneSelf {
+			if b >= 0x20 && b != '"' && b != '\\' {
+				i++
+				continue
+			}
+			buf = append(buf, s[start:i]...)
+			switch b {
+			case '"', '\\':
+				buf = append(buf, '\\', b)
+			case '\n':
+				buf = append(buf, '\\', 'n')
+			case '\r':
+				buf = append(buf, '\\', 'r')
+			case '\t':
+				buf = append(buf, '\\', 't')
+			default:
+				buf = append(buf, '\\', 'u', '0', '0', hex[b>>4], hex[b&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			buf = append(buf, s[start:i]...)
+			buf = append(buf, `\ufffd`...)
+			i += size
+			start = i
+			continue
+		}
+		// U+2028 and U+2029 are valid JSON but break JavaScript.
+		if r == '\u2028' || r == '\u2029' {
+			buf = append(buf, s[start:i]...)
+			buf = append(buf, '\\', 'u', '2', '0', '2', hex[r&0xF])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	buf = append(buf, s[start:]...)
+	return append(buf, '"')
 }
 
 const MaxJsonInt = 1<<53 - 1
 
 func (je JsonEmitter) EmitInt(i int64, asKey bool) error {
 	if asKey || (i > MaxJsonInt) {
-		return je.EmitString(fmt.Sprintf("~i%d", i), asKey)
+		return je.EmitString("~i"+strconv.FormatInt(i, 10), asKey)
 	}
-	return je.EmitBase(i)
+	je.out.buf = strconv.AppendInt(je.out.buf, i, 10)
+	return je.out.done()
 }
 
 func (je JsonEmitter) EmitNil(asKey bool) error {
 	if asKey {
 		return je.EmitString("~_", false)
 	} else {
-		return je.EmitBase(nil)
+		return je.Emit("null")
 	}
 }
 
 func (je JsonEmitter) EmitFloat(f float64, asKey bool) error {
 	if asKey {
-		return je.EmitString(fmt.Sprintf("~d%g", f), asKey)
+		return je.EmitString("~d"+strconv.FormatFloat(f, 'g', -1, 64), asKey)
 	} else {
-		s := fmt.Sprintf("%g", f)
-		if !strings.ContainsAny(s, ".eE") {
-			s = s + ".0" // Horible hack!
+		start := len(je.out.buf)
+		je.out.buf = strconv.AppendFloat(je.out.buf, f, 'g', -1, 64)
+		if f == math.Trunc(f) && !hasExponent(je.out.buf[start:]) {
+			je.out.buf = append(je.out.buf, ".0"...) // Horible hack!
+		}
+		return je.out.done()
+	}
+}
+
+func hasExponent(b []byte) bool {
+	for _, c := range b {
+		if c == 'e' || c == 'E' {
+			return true
 		}
-		return je.Emit(s)
 	}
+	return false
 }
 
 func (je JsonEmitter) EmitStartArray() error {
@@ -146,7 +302,9 @@ func (je JsonEmitter) EmitBool(x bool, asKey bool) error {
 		} else {
 			return je.EmitString("~?f", false)
 		}
+	} else if x {
+		return je.Emit("true")
 	} else {
-		return je.EmitBase(x)
+		return je.Emit("false")
 	}
 }
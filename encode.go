@@ -19,7 +19,6 @@
 package transit
 
 import (
-	"bytes"
 	"container/list"
 	"github.com/pborman/uuid"
 	"github.com/shopspring/decimal"
@@ -31,18 +30,26 @@ import (
 )
 
 type Encoder struct {
-	emitter       DataEmitter
+	emitter       *JsonEmitter
 	valueEncoders map[interface{}]ValueEncoder
+	plans         *encoderPlans
+	depth         *encodeDepth
+	fallbacks     Fallbacks
+	nilAsEmpty    bool
+	verbose       bool
+	autoFlush     bool
 }
 
 var goListType = reflect.TypeOf(list.New())
 var keywordType = reflect.TypeOf(Keyword(""))
 var symbolType = reflect.TypeOf(Symbol(""))
 var cmapType = reflect.TypeOf(NewCMap())
+var cmapValueType = reflect.TypeOf(CMap{})
 
 var aUrl, _ = url.Parse("http://foo.com")
 var urlType = reflect.TypeOf(aUrl)
 var turiType = reflect.TypeOf(NewTUri("http://example.com"))
+var turiValueType = reflect.TypeOf(TUri{})
 
 var setType = reflect.TypeOf(Set{})
 
@@ -74,7 +81,14 @@ func NewEncoder(w io.Writer, verbose bool) *Encoder {
 	}
 
 	emitter := NewJsonEmitter(w, cache)
-	e := Encoder{emitter: emitter, valueEncoders: valueEncoders}
+	e := Encoder{
+		emitter:       emitter,
+		valueEncoders: valueEncoders,
+		plans:         newEncoderPlans(),
+		depth:         newEncodeDepth(),
+		verbose:       verbose,
+		autoFlush:     true,
+	}
 
 	e.addHandler(reflect.String, NewStringEncoder())
 
@@ -121,9 +135,11 @@ func NewEncoder(w io.Writer, verbose bool) *Encoder {
 	e.addHandler(symbolType, NewSymbolEncoder())
 	e.addHandler(keywordType, NewKeywordEncoder())
 	e.addHandler(cmapType, NewCMapEncoder())
+	e.addHandler(cmapValueType, NewCMapEncoder())
 	e.addHandler(setType, NewSetEncoder())
 	e.addHandler(urlType, NewUrlEncoder())
 	e.addHandler(turiType, NewTUriEncoder())
+	e.addHandler(turiValueType, NewTUriEncoder())
 	e.addHandler(linkType, NewLinkEncoder())
 
 	e.addHandler(taggedValueType, NewTaggedValueEncoder())
@@ -136,6 +152,7 @@ func NewEncoder(w io.Writer, verbose bool) *Encoder {
 // of reflect.Type and the c value should be an encoder for that type.
 func (e Encoder) AddHandler(t reflect.Type, c ValueEncoder) {
 	e.addHandler(t, c)
+	e.plans.handlerAdded(t)
 }
 
 // addHandler adds a new handler to the table, but the untyped first
@@ -150,45 +167,133 @@ func (e Encoder) ValueEncoderFor(v reflect.Value) ValueEncoder {
 	// Nil is a special case since it doesn't really work
 	// very well with the reflect package.
 
-	if v == nilValue {
+	if v == nilValue || e.encodesAsNil(v) {
 		return nilEncoder
 	}
 
-	// Look for an encoder by the specific type.
+	// Look for an encoder by the specific type and then by kind.
+	// The kind lookup will catch values of know kinds, say int64
+	// or string which have a different specific type. If there is
+	// no encoder for either, we end up with the error encoder.
+
+	return e.planFor(v.Type()).encoder
+}
 
-	typeEncoder := e.valueEncoders[v.Type()]
-	if typeEncoder != nil {
-		return typeEncoder
+// emitStartTagged emits the opening of a tagged value: the start of
+// the array, the tag itself and the separator before the value.
+func (e Encoder) emitStartTagged(tag string) error {
+	if err := e.emitter.EmitStartArray(); err != nil {
+		return err
 	}
+	if err := e.emitter.EmitTag(tag); err != nil {
+		return err
+	}
+	return e.emitter.EmitArraySeparator()
+}
 
-	// If we can't find a type encoder, try finding one
-	// by type. This is will catch values of know kinds,
-	// say int64 or string which have a different specific
-	// type.
+// BytesWritten returns the number of bytes that the encoder has
+// successfully written to its stream. After a write error it tells
+// you how much of the output made it out.
+func (e Encoder) BytesWritten() int64 {
+	return e.emitter.BytesWritten()
+}
 
-	kindEncoder := e.valueEncoders[v.Kind()]
-	if kindEncoder != nil {
-		return kindEncoder
-	}
+// SetAutoFlush controls whether Encode writes each value out to
+// the stream as soon as it is encoded, which is the default. With
+// auto flush off, output is only written when the internal buffer
+// fills up or when Flush is called, which saves a great many
+// writes when sending lots of small values.
+func (e *Encoder) SetAutoFlush(autoFlush bool) {
+	e.autoFlush = autoFlush
+}
+
+// SetFallbacks sets what the encoder does with values of types that
+// have no handler. See Fallbacks.
+func (e *Encoder) SetFallbacks(fallbacks Fallbacks) {
+	e.fallbacks = fallbacks
+	e.plans.clear()
+}
+
+// SetMaxDepth sets how deeply the encoder will nest collections and
+// pointers before giving up with a KindLimit error. The default is
+// DefaultMaxDepth; zero or less means no limit. Whatever the limit,
+// values that contain themselves fail with a KindCycle error.
+func (e *Encoder) SetMaxDepth(max int) {
+	e.depth.max = max
+}
+
+// SetNilAsEmpty controls how nil maps and slices are encoded. By
+// default they are written as null, like any other nil value. With
+// nilAsEmpty set they are written as empty collections instead.
+func (e *Encoder) SetNilAsEmpty(nilAsEmpty bool) {
+	e.nilAsEmpty = nilAsEmpty
+}
 
-	// No encoder, for this type, return the error encoder.
-	return NewErrorEncoder()
+// Flush writes any buffered output to the stream.
+func (e Encoder) Flush() error {
+	return e.emitter.Flush()
+}
+
+// Reset discards any buffered output, errors and cached strings and
+// points the encoder at w, so that it can be reused.
+func (e *Encoder) Reset(w io.Writer) {
+	e.emitter.Reset(w)
+	e.clearCache()
+	e.depth.reset()
+}
+
+// clearCache forgets the strings cached so far. Each top level
+// value starts with an empty cache, as decoders expect.
+func (e Encoder) clearCache() {
+	if rc, ok := e.emitter.cache.(*RollingCache); ok {
+		rc.Clear()
+	}
 }
 
 // Given a Value, encode it.
 func (e Encoder) EncodeValue(v reflect.Value, asKey bool) error {
-	valueEncoder := e.ValueEncoderFor(v)
-	return valueEncoder.Encode(e, v, asKey)
+	if v == nilValue {
+		return nilEncoder.Encode(e, v, asKey)
+	}
+	return e.planFor(v.Type()).encode(e, v, asKey)
 }
 
-// Given a raw interface, encode it.
+// Given a raw interface, encode it. The most common types are
+// handled directly, without going through reflection.
 func (e Encoder) EncodeInterface(x interface{}, asKey bool) error {
-	v := reflect.ValueOf(x)
-	return e.EncodeValue(v, asKey)
+	if e.plans.fast {
+		switch v := x.(type) {
+		case nil:
+			return e.emitter.EmitNil(asKey)
+		case string:
+			return encodeString(e, v, asKey)
+		case bool:
+			return e.emitter.EmitBool(v, asKey)
+		case int:
+			return e.emitter.EmitInt(int64(v), asKey)
+		case int64:
+			return e.emitter.EmitInt(v, asKey)
+		case float64:
+			return encodeFloat(e, v, asKey)
+		case Keyword:
+			return e.emitter.EmitString(startKW+string(v), true)
+		case []interface{}:
+			return e.encodeGenericArray(v, asKey)
+		case map[interface{}]interface{}:
+			return e.encodeGenericMap(v, asKey)
+		case map[string]interface{}:
+			return e.encodeStringMap(v, asKey)
+		}
+	}
+
+	return e.EncodeValue(reflect.ValueOf(x), asKey)
 }
 
 // Encode a value at the top level.
 func (e Encoder) Encode(x interface{}) error {
+	e.clearCache()
+	e.depth.reset()
+
 	v := reflect.ValueOf(x)
 	valueEncoder := e.ValueEncoderFor(v)
 
@@ -196,17 +301,21 @@ func (e Encoder) Encode(x interface{}) error {
 		x = TaggedValue{TagId("'"), x}
 	}
 
-	return e.EncodeInterface(x, false)
+	if err := e.EncodeInterface(x, false); err != nil {
+		return err
+	}
+
+	if e.autoFlush {
+		return e.Flush()
+	}
+	return nil
 }
 
 // Encode the given value to a string.
 func EncodeToString(x interface{}, verbose bool) (string, error) {
-	var buf bytes.Buffer
-	var encoder = NewEncoder(&buf, verbose)
-	err := encoder.Encode(x)
-
+	b, err := marshalAppend(nil, x, verbose)
 	if err != nil {
 		return "", err
 	}
-	return buf.String(), nil
+	return string(b), nil
 }
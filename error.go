@@ -18,15 +18,191 @@
 
 package transit
 
+import (
+	"fmt"
+)
+
+// ErrorKind classifies a TransitError so that callers can react to
+// broad categories of failure without parsing messages. ErrorKind
+// values are also errors, so errors.Is(err, KindLimit) reports
+// whether err is a TransitError of that kind.
+type ErrorKind int
+
+const (
+	// KindOther is used for errors that have not been classified.
+	KindOther ErrorKind = iota
+
+	// KindSyntax means that the input is not well formed transit.
+	KindSyntax
+
+	// KindUnknownType means that there is no handler for a value.
+	KindUnknownType
+
+	// KindLimit means that decoding stopped because the input went
+	// past one of the Decoder's Limits, or that encoding stopped at
+	// the Encoder's maximum depth.
+	KindLimit
+
+	// KindHandler means that a read or write handler failed.
+	KindHandler
+
+	// KindWrite means that the underlying writer failed.
+	KindWrite
+
+	// KindCycle means that a value being encoded contains itself.
+	KindCycle
+
+	// KindType means that a decoded value is not of the type asked
+	// for, say by DecodeAs or Get.
+	KindType
+)
+
+func (k ErrorKind) String() string {
+	switch k {
+	case KindSyntax:
+		return "syntax error"
+	case KindUnknownType:
+		return "unknown type"
+	case KindLimit:
+		return "limit exceeded"
+	case KindHandler:
+		return "handler failed"
+	case KindWrite:
+		return "write failed"
+	case KindCycle:
+		return "cycle"
+	case KindType:
+		return "wrong type"
+	default:
+		return "other"
+	}
+}
+
+func (k ErrorKind) Error() string {
+	return k.String()
+}
+
+// TransitError is the error type returned by the encoder and
+// the decoder.
 type TransitError struct {
-	Message string      // Describe the error.
-	Source  interface{} // The value that cause the problem.
+	Kind    ErrorKind     // The broad category of the error.
+	Message string        // Describe the error.
+	Source  interface{}   // The value that cause the problem.
+	Path    []interface{} // The array indexes and map keys leading to Source.
+	Offset  int64         // Stream offset where reading stopped.
+	Written int64         // Bytes written before a write error.
+	Err     error         // The underlying error, if any.
+
+	keys *pathKeys // where Path was built, see withPath
 }
 
 func NewTransitError(msg string, v interface{}) *TransitError {
 	return &TransitError{Message: msg, Source: v}
 }
 
+func newSyntaxError(msg string, v interface{}, err error) *TransitError {
+	return &TransitError{Kind: KindSyntax, Message: msg, Source: v, Err: err}
+}
+
 func (e *TransitError) Error() string {
-	return e.Message
+	msg := e.Message
+	if len(e.Path) > 0 {
+		msg = fmt.Sprintf("%s at %v", msg, e.Path)
+	}
+	if e.Err != nil {
+		msg = msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+// Unwrap returns the underlying error, if any.
+func (e *TransitError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is the ErrorKind of this error.
+func (e *TransitError) Is(target error) bool {
+	kind, ok := target.(ErrorKind)
+	return ok && kind == e.Kind
+}
+
+// withPath records that err happened inside the array element or
+// map entry identified by key. Since errors are annotated as they
+// travel back up through the nested collections, the path ends up
+// outermost first. An error may be kept and returned again, as the
+// emitter does after a write fails, so the path goes on a copy.
+func withPath(err error, key interface{}) error {
+	if te, ok := err.(*TransitError); ok {
+		c := *te
+		c.Path, c.keys = te.keys.prepend(te.Path, key)
+		return &c
+	}
+	return err
+}
+
+// pathKeys holds the keys of error paths, filled in from the end, so
+// that adding each key on the way out of a deeply nested value does
+// not copy the whole path again. The copies of an error on the way
+// out share one pathKeys; only the copy whose Path starts at start may
+// add to it in place.
+type pathKeys struct {
+	keys  []interface{}
+	start int
+}
+
+func (pk *pathKeys) prepend(path []interface{}, key interface{}) ([]interface{}, *pathKeys) {
+	if pk != nil && pk.start > 0 && pk.owns(path) {
+		pk.start--
+		pk.keys[pk.start] = key
+		return pk.keys[pk.start:], pk
+	}
+
+	n := 2*len(path) + 8
+	grown := &pathKeys{keys: make([]interface{}, n), start: n - len(path) - 1}
+	grown.keys[grown.start] = key
+	copy(grown.keys[grown.start+1:], path)
+	return grown.keys[grown.start:], grown
+}
+
+// owns reports whether path is the one most recently built in pk.
+func (pk *pathKeys) owns(path []interface{}) bool {
+	if len(path) != len(pk.keys)-pk.start {
+		return false
+	}
+	return len(path) == 0 || &path[0] == &pk.keys[pk.start]
+}
+
+// at returns a copy of e with its Offset set to offset.
+func (e *TransitError) at(offset int64) *TransitError {
+	c := *e
+	c.Offset = offset
+	return &c
+}
+
+// handlerError makes sure that an error coming back from a handler
+// is a TransitError with a meaningful kind.
+func handlerError(tag string, err error) error {
+	if te, ok := err.(*TransitError); ok {
+		if te.Kind == KindOther {
+			c := *te
+			c.Kind = KindHandler
+			return &c
+		}
+		return te
+	}
+	return &TransitError{Kind: KindHandler, Message: "Handler for " + tag + " failed", Source: tag, Err: err}
+}
+
+// readerError makes sure that an error coming back from an array or
+// map reader is a TransitError with a meaningful kind.
+func readerError(err error) error {
+	if te, ok := err.(*TransitError); ok {
+		if te.Kind == KindOther {
+			c := *te
+			c.Kind = KindHandler
+			return &c
+		}
+		return te
+	}
+	return &TransitError{Kind: KindHandler, Message: "Collection reader failed", Err: err}
 }
@@ -0,0 +1,140 @@
+// Copyright 2016 Russ Olsen. All Rights Reserved.
+//
+// This code is a Go port of the Java version created and maintained by Cognitect, therefore:
+//
+// Copyright 2014 Cognitect. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS-IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transit
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func VerifyError(t *testing.T, err error, kind ErrorKind, path string) {
+	var te *TransitError
+
+	if !errors.As(err, &te) {
+		t.Errorf("Expected a TransitError, got: %v", err)
+		return
+	}
+
+	if !errors.Is(err, kind) {
+		t.Errorf("Expected error kind [%v], got [%v]: %v", kind, te.Kind, err)
+	}
+
+	if actual := fmt.Sprintf("%v", te.Path); actual != path {
+		t.Errorf("Expected error path %v, got %v: %v", path, actual, err)
+	}
+}
+
+func TestReadErrorKinds(t *testing.T) {
+	_, err := DecodeFromString(`[1, 2, 3, ["^ ", "~:user", ["^ ", "~:tags", ["a", "~ixyz"]]]]`)
+	VerifyError(t, err, KindSyntax, "[3 :user :tags 1]")
+
+	var numError *strconv.NumError
+	if !errors.As(err, &numError) {
+		t.Errorf("Expected the underlying strconv error to be available: %v", err)
+	}
+
+	_, err = DecodeFromString(`{"a": ["~zXYZ"]}`)
+	VerifyError(t, err, KindSyntax, "[a 0]")
+
+	_, err = DecodeFromString(`[1, 2`)
+	VerifyError(t, err, KindSyntax, "[]")
+
+	_, err = DecodeFromString(`[{"~#cmap": [1]}]`)
+	VerifyError(t, err, KindSyntax, "[0]")
+}
+
+func TestHandlerErrors(t *testing.T) {
+	failure := errors.New("no points today")
+
+	d := NewDecoder(strings.NewReader(`[1, {"~#point": [1, 2]}]`))
+	d.AddHandler("point", func(d Decoder, x interface{}) (interface{}, error) {
+		return nil, failure
+	})
+
+	_, err := d.Decode()
+	VerifyError(t, err, KindHandler, "[1]")
+
+	if !errors.Is(err, failure) {
+		t.Errorf("Expected the handler's error to be wrapped: %v", err)
+	}
+}
+
+func TestHandlerErrorsKept(t *testing.T) {
+	failure := NewTransitError("no points today", nil)
+
+	d := NewDecoder(strings.NewReader(`[1, {"~#point": [1, 2]}]`))
+	d.AddHandler("point", func(d Decoder, x interface{}) (interface{}, error) {
+		return nil, failure
+	})
+
+	_, err := d.Decode()
+	VerifyError(t, err, KindHandler, "[1]")
+
+	if failure.Kind != KindOther || failure.Path != nil {
+		t.Errorf("Expected the handler's own error to be left alone, got %v %v", failure.Kind, failure.Path)
+	}
+}
+
+func TestWriteErrorKinds(t *testing.T) {
+	type unknown struct{}
+
+	value := map[Keyword]interface{}{
+		Keyword("user"): []interface{}{1, "two", unknown{}},
+	}
+
+	_, err := EncodeToString(value, false)
+	VerifyError(t, err, KindUnknownType, "[:user 2]")
+}
+
+func TestErrorPathCopies(t *testing.T) {
+	inner := newSyntaxError("bad", nil, nil)
+	a := withPath(withPath(inner, 2), 1)
+	b := withPath(a, "b")
+	c := withPath(a, "c")
+	d := withPath(withPath(inner, 3), 1)
+
+	VerifyError(t, inner, KindSyntax, "[]")
+	VerifyError(t, a, KindSyntax, "[1 2]")
+	VerifyError(t, b, KindSyntax, "[b 1 2]")
+	VerifyError(t, c, KindSyntax, "[c 1 2]")
+	VerifyError(t, d, KindSyntax, "[1 3]")
+}
+
+func TestErrorPathDeep(t *testing.T) {
+	depth := maxNesting + 1
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+
+	_, err := DecodeFromString(strings.Repeat("[", depth))
+
+	runtime.ReadMemStats(&after)
+	te := err.(*TransitError)
+	if te.Kind != KindLimit || len(te.Path) != depth-1 {
+		t.Fatalf("Expected a limit error %v deep, got %v deep: %v", depth-1, len(te.Path), te.Kind)
+	}
+
+	// Copying the path at every level would take hundreds of megabytes.
+	if used := after.TotalAlloc - before.TotalAlloc; used > 32<<20 {
+		t.Errorf("Expected building the path to be cheap, but it took %v bytes", used)
+	}
+}
@@ -0,0 +1,424 @@
+// Copyright 2016 Russ Olsen. All Rights Reserved.
+//
+// This code is a Go port of the Java version created and maintained by Cognitect, therefore:
+//
+// Copyright 2014 Cognitect. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS-IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ext provides read and write handlers for common Go types
+// that transit has no ground type for. Each type is written as a tagged
+// value with one of the tags below, so that other transit
+// implementations can add matching handlers.
+//
+//	Tag       Go types                        Rep
+//	duration  time.Duration                   integer number of nanoseconds
+//	ip        net.IP, netip.Addr              string, as in "192.0.2.1" or "2001:db8::1"
+//	cidr      netip.Prefix, *net.IPNet        string, as in "192.0.2.0/24"
+//	complex   complex64, complex128           array of two floats, [real, imag]
+//	regex     *regexp.Regexp                  string holding the RE2 pattern
+//	query     url.Values                      string, URL encoded as in "a=1&b=2"
+//	tz        *time.Location                  string holding the IANA zone name
+//
+// The zero netip.Addr and netip.Prefix are written as null, as a nil
+// net.IP is.
+//
+// UUIDs held in a named [16]byte type, the representation google/uuid
+// uses, can be written with transit's own uuid type, ~u, by adding the
+// type with RegisterUUIDType. Plain [16]byte values, such as the sums
+// from crypto/md5, are left alone.
+//
+// Read back, durations decode to time.Duration, ip to netip.Addr,
+// cidr to netip.Prefix, complex to complex128, regex to *regexp.Regexp,
+// query to url.Values and tz to *time.Location. Uuids keep decoding to
+// the core uuid type unless RegisterUUIDDecoder is used.
+package ext
+
+import (
+	"fmt"
+	"github.com/pborman/uuid"
+	"github.com/russolsen/transit"
+	"math"
+	"net"
+	"net/netip"
+	"net/url"
+	"reflect"
+	"regexp"
+	"time"
+)
+
+// The tags written by the handlers in this package.
+const (
+	DurationTag = "duration"
+	IPTag       = "ip"
+	CIDRTag     = "cidr"
+	ComplexTag  = "complex"
+	RegexTag    = "regex"
+	QueryTag    = "query"
+	TimeZoneTag = "tz"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+var ipType = reflect.TypeOf(net.IP{})
+var addrType = reflect.TypeOf(netip.Addr{})
+var prefixType = reflect.TypeOf(netip.Prefix{})
+var ipNetType = reflect.TypeOf(&net.IPNet{})
+var complex64Type = reflect.TypeOf(complex64(0))
+var complex128Type = reflect.TypeOf(complex128(0))
+var regexpType = reflect.TypeOf(&regexp.Regexp{})
+var valuesType = reflect.TypeOf(url.Values{})
+var locationType = reflect.TypeOf(time.UTC)
+
+// RegisterEncoder adds the write handlers from this package to e.
+// Named [16]byte types, such as google/uuid's UUID, need to be added
+// separately with RegisterUUIDType, since e cannot know about them.
+func RegisterEncoder(e *transit.Encoder) {
+	e.AddHandler(durationType, NewDurationEncoder())
+	e.AddHandler(ipType, NewIPEncoder())
+	e.AddHandler(addrType, NewIPEncoder())
+	e.AddHandler(prefixType, NewCIDREncoder())
+	e.AddHandler(ipNetType, NewCIDREncoder())
+	e.AddHandler(complex64Type, NewComplexEncoder())
+	e.AddHandler(complex128Type, NewComplexEncoder())
+	e.AddHandler(regexpType, NewRegexEncoder())
+	e.AddHandler(valuesType, NewQueryEncoder())
+	e.AddHandler(locationType, NewTimeZoneEncoder())
+}
+
+// RegisterUUIDType makes e write values of t, which must be a named
+// [16]byte type, as transit uuids.
+func RegisterUUIDType(e *transit.Encoder, t reflect.Type) error {
+	if t.Name() == "" || !isUUIDType(t) {
+		msg := fmt.Sprintf("Not a named 16 byte UUID type: %v", t)
+		return &transit.TransitError{Kind: transit.KindType, Message: msg, Source: t}
+	}
+	e.AddHandler(t, NewUUIDEncoder())
+	return nil
+}
+
+func isUUIDType(t reflect.Type) bool {
+	return t.Kind() == reflect.Array && t.Len() == 16 && t.Elem().Kind() == reflect.Uint8
+}
+
+// RegisterDecoder adds the read handlers from this package to d.
+func RegisterDecoder(d *transit.Decoder) {
+	d.AddHandler(DurationTag, DecodeDuration)
+	d.AddHandler(IPTag, DecodeIP)
+	d.AddHandler(CIDRTag, DecodeCIDR)
+	d.AddHandler(ComplexTag, DecodeComplex)
+	d.AddHandler(RegexTag, DecodeRegex)
+	d.AddHandler(QueryTag, DecodeQuery)
+	d.AddHandler(TimeZoneTag, DecodeTimeZone)
+}
+
+// RegisterUUIDDecoder replaces the handler for transit uuids in d, so
+// that they decode to [16]byte rather than to a github.com/pborman/uuid
+// UUID.
+func RegisterUUIDDecoder(d *transit.Decoder) {
+	d.AddHandler("u", DecodeUUID)
+}
+
+func tagged(tag string, rep interface{}) transit.TaggedValue {
+	return transit.TaggedValue{Tag: transit.TagId(tag), Value: rep}
+}
+
+func syntaxError(msg string, v interface{}, err error) error {
+	return &transit.TransitError{Kind: transit.KindSyntax, Message: msg, Source: v, Err: err}
+}
+
+// untag returns the rep of a tagged value.
+func untag(x interface{}) interface{} {
+	if tv, ok := x.(transit.TaggedValue); ok {
+		return tv.Value
+	}
+	return x
+}
+
+func stringRep(what string, x interface{}) (string, error) {
+	s, ok := untag(x).(string)
+	if !ok {
+		return "", syntaxError(what+" is not a string.", x, nil)
+	}
+	return s, nil
+}
+
+type DurationEncoder struct{}
+
+func NewDurationEncoder() *DurationEncoder {
+	return &DurationEncoder{}
+}
+
+func (de DurationEncoder) IsStringable(v reflect.Value) bool {
+	return false
+}
+
+func (de DurationEncoder) Encode(e transit.Encoder, v reflect.Value, asKey bool) error {
+	return e.EncodeInterface(tagged(DurationTag, v.Int()), asKey)
+}
+
+// DecodeDuration decodes a count of nanoseconds into a time.Duration.
+func DecodeDuration(d transit.Decoder, x interface{}) (interface{}, error) {
+	n, ok := untag(x).(int64)
+	if !ok {
+		return nil, syntaxError("Duration is not an integer.", x, nil)
+	}
+	return time.Duration(n), nil
+}
+
+type IPEncoder struct{}
+
+func NewIPEncoder() *IPEncoder {
+	return &IPEncoder{}
+}
+
+func (ie IPEncoder) IsStringable(v reflect.Value) bool {
+	return false
+}
+
+// Encode writes the zero netip.Addr as null, like a nil net.IP, since
+// it is no address at all.
+func (ie IPEncoder) Encode(e transit.Encoder, v reflect.Value, asKey bool) error {
+	var s string
+	switch ip := v.Interface().(type) {
+	case net.IP:
+		if len(ip) != net.IPv4len && len(ip) != net.IPv6len {
+			msg := fmt.Sprintf("Not an IP address: %d bytes", len(ip))
+			return &transit.TransitError{Kind: transit.KindType, Message: msg, Source: ip}
+		}
+		s = ip.String()
+	case netip.Addr:
+		if !ip.IsValid() {
+			return e.EncodeInterface(nil, asKey)
+		}
+		s = ip.String()
+	}
+	return e.EncodeInterface(tagged(IPTag, s), asKey)
+}
+
+// DecodeIP decodes an IP address into a netip.Addr. Unlike net.IP,
+// netip.Addr is comparable, so addresses can be used as map keys.
+func DecodeIP(d transit.Decoder, x interface{}) (interface{}, error) {
+	s, err := stringRep("IP address", x)
+	if err != nil {
+		return nil, err
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return nil, syntaxError("Bad IP address", s, err)
+	}
+	return addr, nil
+}
+
+type CIDREncoder struct{}
+
+func NewCIDREncoder() *CIDREncoder {
+	return &CIDREncoder{}
+}
+
+func (ce CIDREncoder) IsStringable(v reflect.Value) bool {
+	return false
+}
+
+// Encode writes the zero netip.Prefix as null, as IPEncoder does the
+// zero netip.Addr.
+func (ce CIDREncoder) Encode(e transit.Encoder, v reflect.Value, asKey bool) error {
+	var s string
+	switch p := v.Interface().(type) {
+	case netip.Prefix:
+		if !p.IsValid() {
+			return e.EncodeInterface(nil, asKey)
+		}
+		s = p.String()
+	case *net.IPNet:
+		s = p.String()
+	}
+	return e.EncodeInterface(tagged(CIDRTag, s), asKey)
+}
+
+// DecodeCIDR decodes an address prefix into a netip.Prefix.
+func DecodeCIDR(d transit.Decoder, x interface{}) (interface{}, error) {
+	s, err := stringRep("CIDR prefix", x)
+	if err != nil {
+		return nil, err
+	}
+	p, err := netip.ParsePrefix(s)
+	if err != nil {
+		return nil, syntaxError("Bad CIDR prefix", s, err)
+	}
+	return p, nil
+}
+
+type ComplexEncoder struct{}
+
+func NewComplexEncoder() *ComplexEncoder {
+	return &ComplexEncoder{}
+}
+
+func (ce ComplexEncoder) IsStringable(v reflect.Value) bool {
+	return false
+}
+
+func (ce ComplexEncoder) Encode(e transit.Encoder, v reflect.Value, asKey bool) error {
+	c := v.Complex()
+	return e.EncodeInterface(tagged(ComplexTag, []interface{}{real(c), imag(c)}), asKey)
+}
+
+func toFloat(x interface{}) (float64, bool) {
+	switch f := x.(type) {
+	case float64:
+		return f, true
+	case int64:
+		return float64(f), true
+	}
+	return math.NaN(), false
+}
+
+// DecodeComplex decodes a [real, imag] pair into a complex128.
+func DecodeComplex(d transit.Decoder, x interface{}) (interface{}, error) {
+	parts, ok := untag(x).([]interface{})
+	if !ok || len(parts) != 2 {
+		return nil, syntaxError("Complex contents are not a pair.", x, nil)
+	}
+	re, ok1 := toFloat(parts[0])
+	im, ok2 := toFloat(parts[1])
+	if !ok1 || !ok2 {
+		return nil, syntaxError("Complex contents are not numbers.", x, nil)
+	}
+	return complex(re, im), nil
+}
+
+type RegexEncoder struct{}
+
+func NewRegexEncoder() *RegexEncoder {
+	return &RegexEncoder{}
+}
+
+func (re RegexEncoder) IsStringable(v reflect.Value) bool {
+	return false
+}
+
+func (re RegexEncoder) Encode(e transit.Encoder, v reflect.Value, asKey bool) error {
+	r := v.Interface().(*regexp.Regexp)
+	return e.EncodeInterface(tagged(RegexTag, r.String()), asKey)
+}
+
+// DecodeRegex compiles a pattern into a *regexp.Regexp.
+func DecodeRegex(d transit.Decoder, x interface{}) (interface{}, error) {
+	s, err := stringRep("Regex", x)
+	if err != nil {
+		return nil, err
+	}
+	r, err := regexp.Compile(s)
+	if err != nil {
+		return nil, syntaxError("Bad regex", s, err)
+	}
+	return r, nil
+}
+
+type QueryEncoder struct{}
+
+func NewQueryEncoder() *QueryEncoder {
+	return &QueryEncoder{}
+}
+
+func (qe QueryEncoder) IsStringable(v reflect.Value) bool {
+	return false
+}
+
+func (qe QueryEncoder) Encode(e transit.Encoder, v reflect.Value, asKey bool) error {
+	q := v.Interface().(url.Values)
+	return e.EncodeInterface(tagged(QueryTag, q.Encode()), asKey)
+}
+
+// DecodeQuery decodes a URL encoded query string into url.Values.
+func DecodeQuery(d transit.Decoder, x interface{}) (interface{}, error) {
+	s, err := stringRep("Query", x)
+	if err != nil {
+		return nil, err
+	}
+	q, err := url.ParseQuery(s)
+	if err != nil {
+		return nil, syntaxError("Bad query", s, err)
+	}
+	return q, nil
+}
+
+type TimeZoneEncoder struct{}
+
+func NewTimeZoneEncoder() *TimeZoneEncoder {
+	return &TimeZoneEncoder{}
+}
+
+func (te TimeZoneEncoder) IsStringable(v reflect.Value) bool {
+	return false
+}
+
+func (te TimeZoneEncoder) Encode(e transit.Encoder, v reflect.Value, asKey bool) error {
+	loc := v.Interface().(*time.Location)
+	return e.EncodeInterface(tagged(TimeZoneTag, loc.String()), asKey)
+}
+
+// DecodeTimeZone loads the named zone from the system's time zone
+// database.
+func DecodeTimeZone(d transit.Decoder, x interface{}) (interface{}, error) {
+	s, err := stringRep("Time zone", x)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := time.LoadLocation(s)
+	if err != nil {
+		return nil, syntaxError("Unknown time zone", s, err)
+	}
+	return loc, nil
+}
+
+// UUIDEncoder writes a [16]byte type as a transit uuid. Add it for
+// named UUID types with RegisterUUIDType, for example:
+//
+//	ext.RegisterUUIDType(e, reflect.TypeOf(uuid.UUID{}))
+type UUIDEncoder struct{}
+
+func NewUUIDEncoder() *UUIDEncoder {
+	return &UUIDEncoder{}
+}
+
+func (ue UUIDEncoder) IsStringable(v reflect.Value) bool {
+	return true
+}
+
+func (ue UUIDEncoder) Encode(e transit.Encoder, v reflect.Value, asKey bool) error {
+	if !isUUIDType(v.Type()) {
+		msg := fmt.Sprintf("Not a 16 byte UUID: %v", v.Type())
+		return &transit.TransitError{Kind: transit.KindType, Message: msg, Source: v.Interface()}
+	}
+	u := make(uuid.UUID, 16)
+	for i := range u {
+		u[i] = byte(v.Index(i).Uint())
+	}
+	return e.EncodeInterface(u, asKey)
+}
+
+// DecodeUUID decodes a transit uuid into a [16]byte.
+func DecodeUUID(d transit.Decoder, x interface{}) (interface{}, error) {
+	s, err := stringRep("UUID", x)
+	if err != nil {
+		return nil, err
+	}
+	u := uuid.Parse(s)
+	if u == nil {
+		return nil, syntaxError("Unable to parse uuid", s, nil)
+	}
+	var result [16]byte
+	copy(result[:], u)
+	return result, nil
+}
@@ -0,0 +1,198 @@
+// Copyright 2016 Russ Olsen. All Rights Reserved.
+//
+// This code is a Go port of the Java version created and maintained by Cognitect, therefore:
+//
+// Copyright 2014 Cognitect. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS-IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ext
+
+import (
+	"bytes"
+	"errors"
+	"github.com/pborman/uuid"
+	"github.com/russolsen/transit"
+	"net"
+	"net/netip"
+	"net/url"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func roundTrip(t *testing.T, value interface{}) (string, interface{}) {
+	var buf bytes.Buffer
+	e := transit.NewEncoder(&buf, false)
+	RegisterEncoder(e)
+	if err := e.Encode(value); err != nil {
+		t.Fatalf("Unexpected error encoding %v: %v", value, err)
+	}
+
+	d := transit.NewDecoder(bytes.NewReader(buf.Bytes()))
+	RegisterDecoder(d)
+	result, err := d.Decode()
+	if err != nil {
+		t.Fatalf("Unexpected error decoding %v: %v", buf.String(), err)
+	}
+	return buf.String(), result
+}
+
+// uuidLike is a named [16]byte, as in google/uuid.
+type uuidLike [16]byte
+
+func TestExtRoundTrip(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("No time zone database: %v", err)
+	}
+
+	tests := []struct {
+		value    interface{}
+		encoded  string
+		expected interface{}
+	}{
+		{90 * time.Second, `["~#duration",90000000000]`, 90 * time.Second},
+		{net.ParseIP("192.0.2.1"), `["~#ip","192.0.2.1"]`, netip.MustParseAddr("192.0.2.1")},
+		{netip.MustParseAddr("2001:db8::1"), `["~#ip","2001:db8::1"]`, netip.MustParseAddr("2001:db8::1")},
+		{netip.MustParsePrefix("192.0.2.0/24"), `["~#cidr","192.0.2.0/24"]`, netip.MustParsePrefix("192.0.2.0/24")},
+		{complex(1.5, -2), `["~#complex",[1.5,-2.0]]`, complex(1.5, -2)},
+		{complex64(complex(0, 1)), `["~#complex",[0.0,1.0]]`, complex(0, 1)},
+		{url.Values{"b": {"2"}, "a": {"1", "x y"}}, `["~#query","a=1&a=x+y&b=2"]`, url.Values{"a": {"1", "x y"}, "b": {"2"}}},
+		{ny, `["~#tz","America/New_York"]`, ny},
+	}
+
+	for _, test := range tests {
+		encoded, result := roundTrip(t, []interface{}{test.value})
+		if encoded != "["+test.encoded+"]" {
+			t.Errorf("Expected %v to encode as [%v], got %v", test.value, test.encoded, encoded)
+		}
+		if actual := result.([]interface{})[0]; !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("Expected %v to read back as %#v, got %#v", test.value, test.expected, actual)
+		}
+	}
+}
+
+func TestExtRegexAndNets(t *testing.T) {
+	_, ipNet, _ := net.ParseCIDR("10.0.0.0/8")
+	value := []interface{}{regexp.MustCompile(`^a+b?$`), ipNet}
+
+	encoded, result := roundTrip(t, value)
+	if expected := `[["~#regex","~^a+b?$"],["~#cidr","10.0.0.0/8"]]`; encoded != expected {
+		t.Errorf("Expected %v, got %v", expected, encoded)
+	}
+
+	array := result.([]interface{})
+	if r := array[0].(*regexp.Regexp); !r.MatchString("aab") || r.MatchString("ba") {
+		t.Errorf("Regex %v did not survive the round trip", r)
+	}
+	if p := array[1].(netip.Prefix); p != netip.MustParsePrefix("10.0.0.0/8") {
+		t.Errorf("Expected 10.0.0.0/8, got %v", p)
+	}
+}
+
+func TestExtNamedUUID(t *testing.T) {
+	var buf bytes.Buffer
+	e := transit.NewEncoder(&buf, false)
+	RegisterEncoder(e)
+	if err := RegisterUUIDType(e, reflect.TypeOf(uuidLike{})); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	sum := [16]byte{15: 1}
+	if err := e.Encode([]interface{}{uuidLike{15: 1}, sum}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := `["~u00000000-0000-0000-0000-000000000001",[0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,1]]`
+	if buf.String() != expected {
+		t.Errorf("Expected %v, got %v", expected, buf.String())
+	}
+
+	d := transit.NewDecoder(bytes.NewReader(buf.Bytes()))
+	RegisterDecoder(d)
+	result, err := d.Decode()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, ok := result.([]interface{})[0].(uuid.UUID); !ok {
+		t.Errorf("Expected RegisterDecoder to leave uuids alone, got %#v", result)
+	}
+
+	d = transit.NewDecoder(bytes.NewReader(buf.Bytes()))
+	RegisterUUIDDecoder(d)
+	result, err = d.Decode()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if id := result.([]interface{})[0]; id != sum {
+		t.Errorf("Expected %v, got %#v", sum, id)
+	}
+}
+
+func TestExtUUIDTypeErrors(t *testing.T) {
+	e := transit.NewEncoder(&bytes.Buffer{}, false)
+
+	for _, value := range []interface{}{[16]byte{}, "abc", [8]byte{}} {
+		if err := RegisterUUIDType(e, reflect.TypeOf(value)); !errors.Is(err, transit.KindType) {
+			t.Errorf("Expected a type error registering %T, got %v", value, err)
+		}
+	}
+
+	type shortID [8]byte
+	e.AddHandler(reflect.TypeOf(shortID{}), NewUUIDEncoder())
+	if err := e.Encode(shortID{}); !errors.Is(err, transit.KindType) {
+		t.Errorf("Expected a type error, got %v", err)
+	}
+}
+
+func TestExtNil(t *testing.T) {
+	var ip net.IP
+	var r *regexp.Regexp
+	var q url.Values
+	encoded, result := roundTrip(t, []interface{}{ip, r, q, netip.Addr{}, netip.Prefix{}})
+	if expected := `[null,null,null,null,null]`; encoded != expected {
+		t.Errorf("Expected %v, got %v", expected, encoded)
+	}
+	if expected := []interface{}{nil, nil, nil, nil, nil}; !reflect.DeepEqual(result, expected) {
+		t.Errorf("Expected %v, got %v", expected, result)
+	}
+
+	e := transit.NewEncoder(&bytes.Buffer{}, false)
+	RegisterEncoder(e)
+	if err := e.Encode(net.IP{1, 2, 3}); !errors.Is(err, transit.KindType) {
+		t.Errorf("Expected a type error for a 3 byte IP, got %v", err)
+	}
+}
+
+func TestExtBadReps(t *testing.T) {
+	inputs := []string{
+		`["~#duration","soon"]`,
+		`["~#ip","300.1.1.1"]`,
+		`["~#cidr","10.0.0.0"]`,
+		`["~#complex",[1]]`,
+		`["~#regex","("]`,
+		`["~#query","a=%zz"]`,
+		`["~#tz","Nowhere/Special"]`,
+		`"~unot-a-uuid"`,
+	}
+
+	for _, input := range inputs {
+		d := transit.NewDecoder(bytes.NewReader([]byte(input)))
+		RegisterDecoder(d)
+		RegisterUUIDDecoder(d)
+		if _, err := d.Decode(); !errors.Is(err, transit.KindSyntax) {
+			t.Errorf("Expected a syntax error decoding %v, got %v", input, err)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+// Copyright 2016 Russ Olsen. All Rights Reserved.
+//
+// This code is a Go port of the Java version created and maintained by Cognitect, therefore:
+//
+// Copyright 2014 Cognitect. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS-IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transit
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// TransitMarshaler is implemented by types that can turn themselves
+// into a value the encoder knows how to write, for example a
+// TaggedValue.
+type TransitMarshaler interface {
+	MarshalTransit() (interface{}, error)
+}
+
+// Fallbacks controls what the encoder does with values of types it
+// has no handler for. The fallbacks are tried in the order of the
+// fields below. By default they are all off and such values are an
+// error of kind KindUnknownType.
+type Fallbacks struct {
+	TransitMarshaler bool // Encode what MarshalTransit returns.
+	TextMarshaler    bool // Encode the output of MarshalText as a string.
+	JSONMarshaler    bool // Encode the output of MarshalJSON as generic maps and arrays.
+	Stringer         bool // Encode the output of String as a string.
+
+	// TextTag, if set, is the tag for the output of MarshalText. A
+	// one character tag gives a scalar such as "~xtext", a longer one
+	// a tagged value such as ["~#tag", "text"].
+	TextTag string
+}
+
+// DefaultFallbacks turns on every fallback but Stringer, since the
+// output of String is often not meant to be read back.
+var DefaultFallbacks = Fallbacks{TransitMarshaler: true, TextMarshaler: true, JSONMarshaler: true}
+
+var transitMarshalerType = reflect.TypeOf((*TransitMarshaler)(nil)).Elem()
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+var jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+var stringerType = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+
+// fallbackEncoder returns the encoder for a type that has no handler
+// of its own, or nil if no fallback applies. Pointers to types that
+// do have a handler are left to the pointer encoder.
+func (e Encoder) fallbackEncoder(t reflect.Type) ValueEncoder {
+	if t.Kind() == reflect.Ptr && e.valueEncoders[t.Elem()] != nil {
+		return nil
+	}
+
+	f := e.fallbacks
+
+	switch {
+	case f.TransitMarshaler && t.Implements(transitMarshalerType):
+		return NewTransitMarshalerEncoder()
+	case f.TextMarshaler && t.Implements(textMarshalerType):
+		return NewTextMarshalerEncoder(f.TextTag)
+	case f.JSONMarshaler && t.Implements(jsonMarshalerType):
+		return NewJSONMarshalerEncoder()
+	case f.Stringer && t.Implements(stringerType):
+		return NewStringerEncoder()
+	}
+	return nil
+}
+
+func marshalerError(method string, v reflect.Value, err error) error {
+	msg := fmt.Sprintf("%s failed for value of type %v", method, v.Type())
+	return &TransitError{Kind: KindHandler, Message: msg, Source: v.Interface(), Err: err}
+}
+
+type TransitMarshalerEncoder struct{}
+
+func NewTransitMarshalerEncoder() *TransitMarshalerEncoder {
+	return &TransitMarshalerEncoder{}
+}
+
+func (tme TransitMarshalerEncoder) IsStringable(v reflect.Value) bool {
+	return false
+}
+
+func (tme TransitMarshalerEncoder) Encode(e Encoder, v reflect.Value, asKey bool) error {
+	x, err := v.Interface().(TransitMarshaler).MarshalTransit()
+	if err != nil {
+		return marshalerError("MarshalTransit", v, err)
+	}
+	return e.EncodeInterface(x, asKey)
+}
+
+type TextMarshalerEncoder struct {
+	tag string
+}
+
+func NewTextMarshalerEncoder(tag string) *TextMarshalerEncoder {
+	return &TextMarshalerEncoder{tag: tag}
+}
+
+func (tme TextMarshalerEncoder) IsStringable(v reflect.Value) bool {
+	return len(tme.tag) <= 1
+}
+
+func (tme TextMarshalerEncoder) Encode(e Encoder, v reflect.Value, asKey bool) error {
+	text, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+	if err != nil {
+		return marshalerError("MarshalText", v, err)
+	}
+
+	switch len(tme.tag) {
+	case 0:
+		return encodeString(e, string(text), asKey)
+	case 1:
+		return e.emitter.EmitString(start+tme.tag+string(text), asKey)
+	}
+
+	if err := e.emitStartTagged(tme.tag); err != nil {
+		return err
+	}
+	if err := encodeString(e, string(text), false); err != nil {
+		return err
+	}
+	return e.emitter.EmitEndArray()
+}
+
+type JSONMarshalerEncoder struct{}
+
+func NewJSONMarshalerEncoder() *JSONMarshalerEncoder {
+	return &JSONMarshalerEncoder{}
+}
+
+func (jme JSONMarshalerEncoder) IsStringable(v reflect.Value) bool {
+	return false
+}
+
+func (jme JSONMarshalerEncoder) Encode(e Encoder, v reflect.Value, asKey bool) error {
+	b, err := v.Interface().(json.Marshaler).MarshalJSON()
+	if err != nil {
+		return marshalerError("MarshalJSON", v, err)
+	}
+
+	jsd := json.NewDecoder(bytes.NewReader(b))
+	jsd.UseNumber()
+
+	var x interface{}
+	if err := jsd.Decode(&x); err != nil {
+		return marshalerError("MarshalJSON", v, err)
+	}
+	return e.EncodeInterface(fromJSON(x), asKey)
+}
+
+// fromJSON replaces the json.Numbers in a value decoded by
+// encoding/json with int64 or float64 values.
+func fromJSON(x interface{}) interface{} {
+	switch v := x.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case []interface{}:
+		for i := range v {
+			v[i] = fromJSON(v[i])
+		}
+	case map[string]interface{}:
+		for k := range v {
+			v[k] = fromJSON(v[k])
+		}
+	}
+	return x
+}
+
+type StringerEncoder struct{}
+
+func NewStringerEncoder() *StringerEncoder {
+	return &StringerEncoder{}
+}
+
+func (se StringerEncoder) IsStringable(v reflect.Value) bool {
+	return true
+}
+
+func (se StringerEncoder) Encode(e Encoder, v reflect.Value, asKey bool) error {
+	return encodeString(e, v.Interface().(fmt.Stringer).String(), asKey)
+}
@@ -0,0 +1,108 @@
+// Copyright 2016 Russ Olsen. All Rights Reserved.
+//
+// This code is a Go port of the Java version created and maintained by Cognitect, therefore:
+//
+// Copyright 2014 Cognitect. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS-IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transit
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"net"
+	"net/netip"
+	"testing"
+)
+
+type point struct {
+	x, y int
+}
+
+func (p point) MarshalTransit() (interface{}, error) {
+	return TaggedValue{TagId("point"), []int{p.x, p.y}}, nil
+}
+
+type jsonThing struct{}
+
+func (jt jsonThing) MarshalJSON() ([]byte, error) {
+	return []byte(`{"a": [1, 1.5, "x", null, {"b": true}]}`), nil
+}
+
+type color int
+
+func (c color) String() string {
+	return [...]string{"red", "green"}[c]
+}
+
+type brokenText struct{}
+
+func (bt brokenText) MarshalText() ([]byte, error) {
+	return nil, errors.New("no text today")
+}
+
+func encodeWith(t *testing.T, fallbacks Fallbacks, value interface{}) string {
+	var buf bytes.Buffer
+	e := NewEncoder(&buf, false)
+	e.SetFallbacks(fallbacks)
+
+	if err := e.Encode(value); err != nil {
+		t.Errorf("Unexpected error encoding %v: %v", value, err)
+	}
+	return buf.String()
+}
+
+func TestFallbacksOffByDefault(t *testing.T) {
+	_, err := EncodeToString(netip.MustParseAddr("10.0.0.1"), false)
+	VerifyError(t, err, KindUnknownType, "[]")
+
+	_, err = EncodeToString(point{1, 2}, false)
+	VerifyError(t, err, KindUnknownType, "[]")
+}
+
+func TestFallbacks(t *testing.T) {
+	tests := []struct {
+		fallbacks Fallbacks
+		value     interface{}
+		expected  string
+	}{
+		{DefaultFallbacks, net.ParseIP("127.0.0.1"), `["~#'","127.0.0.1"]`},
+		{DefaultFallbacks, []interface{}{netip.MustParseAddr("10.0.0.1")}, `["10.0.0.1"]`},
+		{DefaultFallbacks, map[netip.Addr]int{netip.MustParseAddr("::1"): 1}, `["^ ","::1",1]`},
+		{DefaultFallbacks, []point{{1, 2}}, `[["~#point",[1,2]]]`},
+		{DefaultFallbacks, jsonThing{}, `["^ ","a",[1,1.5,"x",null,["^ ","b",true]]]`},
+		{DefaultFallbacks, []*big.Int{big.NewInt(12)}, `["~n12"]`},
+		{Fallbacks{TextMarshaler: true, TextTag: "ip"}, []net.IP{net.ParseIP("::1")}, `[["~#ip","::1"]]`},
+		{Fallbacks{TextMarshaler: true, TextTag: "I"}, []net.IP{net.ParseIP("::1")}, `["~I::1"]`},
+		{Fallbacks{Stringer: true}, []color{0, 1}, `["red","green"]`},
+		{DefaultFallbacks, []color{0, 1}, `[0,1]`},
+		{DefaultFallbacks, []*netip.Addr{nil}, `[null]`},
+	}
+
+	for _, test := range tests {
+		if actual := encodeWith(t, test.fallbacks, test.value); actual != test.expected {
+			t.Errorf("Expected %v to encode as %v, got %v", test.value, test.expected, actual)
+		}
+	}
+}
+
+func TestFallbackErrors(t *testing.T) {
+	var buf bytes.Buffer
+	e := NewEncoder(&buf, false)
+	e.SetFallbacks(DefaultFallbacks)
+
+	err := e.Encode([]interface{}{brokenText{}})
+	VerifyError(t, err, KindHandler, "[0]")
+}
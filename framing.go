@@ -0,0 +1,360 @@
+// Copyright 2016 Russ Olsen. All Rights Reserved.
+//
+// This code is a Go port of the Java version created and maintained by Cognitect, therefore:
+//
+// Copyright 2014 Cognitect. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS-IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+)
+
+// FrameFormat says how the values in a framed stream are delimited.
+type FrameFormat int
+
+const (
+	// FrameVarint puts the length of each frame in front of it, as
+	// an unsigned varint like those of encoding/binary.
+	FrameVarint FrameFormat = iota
+
+	// FrameFixed32 puts the length of each frame in front of it, as a
+	// 4 byte big endian integer.
+	FrameFixed32
+
+	// FrameNewline ends each frame with a newline. Transit JSON never
+	// contains a raw newline, so no length is needed.
+	FrameNewline
+)
+
+// DefaultMaxFrameSize is the largest frame that a FramedReader or a
+// FramedWriter handles unless told otherwise.
+const DefaultMaxFrameSize = 16 << 20
+
+// frameTooLarge is the error for a frame of n bytes.
+func frameTooLarge(max int, n uint64) error {
+	return newLimitError("Frame size", int64(max), n)
+}
+
+// FramedWriter writes each value as a frame of its own, with a single
+// call to Write, so that independent messages can be sent over a
+// socket or a pipe and read back one at a time by a FramedReader.
+type FramedWriter struct {
+	w            io.Writer
+	format       FrameFormat
+	encoder      *Encoder
+	buf          []byte
+	maxFrameSize int
+	n            int64
+}
+
+// NewFramedWriter returns a FramedWriter that writes non-verbose
+// transit to w in the given format.
+func NewFramedWriter(w io.Writer, format FrameFormat) *FramedWriter {
+	return &FramedWriter{
+		w:            w,
+		format:       format,
+		encoder:      NewEncoder(nil, false),
+		buf:          make([]byte, 0, bufferSize),
+		maxFrameSize: DefaultMaxFrameSize,
+	}
+}
+
+// Encoder returns the Encoder that the writer uses for each frame, so
+// that handlers and options can be set on it.
+func (fw *FramedWriter) Encoder() *Encoder {
+	return fw.encoder
+}
+
+// SetMaxFrameSize sets the size of the largest frame the writer will
+// write. Zero or less means no limit.
+func (fw *FramedWriter) SetMaxFrameSize(max int) {
+	fw.maxFrameSize = max
+}
+
+// Encode writes x as a single frame. If x cannot be encoded, or its
+// frame is too large, nothing is written.
+func (fw *FramedWriter) Encode(x interface{}) error {
+	// Leave room in front of the value for the largest prefix, so
+	// that the whole frame goes out in one Write.
+	const room = binary.MaxVarintLen64
+
+	out := fw.encoder.emitter.out
+	out.appendTo(fw.buf[:room])
+	err := fw.encoder.Encode(x)
+	fw.buf = out.buf
+	out.appendTo(nil)
+	if err != nil {
+		return err
+	}
+
+	size := len(fw.buf) - room
+	if fw.maxFrameSize > 0 && size > fw.maxFrameSize {
+		return frameTooLarge(fw.maxFrameSize, uint64(size))
+	}
+
+	var frame []byte
+	switch fw.format {
+	case FrameVarint:
+		var prefix [binary.MaxVarintLen64]byte
+		n := binary.PutUvarint(prefix[:], uint64(size))
+		frame = fw.buf[room-n:]
+		copy(frame, prefix[:n])
+	case FrameFixed32:
+		if size > math.MaxUint32 {
+			return frameTooLarge(math.MaxUint32, uint64(size))
+		}
+		frame = fw.buf[room-4:]
+		binary.BigEndian.PutUint32(frame, uint32(size))
+	default:
+		fw.buf = append(fw.buf, '\n')
+		frame = fw.buf[room:]
+	}
+
+	n, err := fw.w.Write(frame)
+	fw.n += int64(n)
+	if err != nil {
+		return &TransitError{Kind: KindWrite, Message: "Write failed", Written: fw.n, Err: err}
+	}
+	return nil
+}
+
+// FramedReader reads the values written by a FramedWriter, one frame
+// at a time. A frame that cannot be decoded, or a newline frame that is
+// too large, is skipped, so that the next call to Decode carries on
+// with the next frame. A length prefix that is malformed or over the
+// limit leaves no way to find the next frame: the reader stops, and
+// every later call to Decode returns the same error.
+type FramedReader struct {
+	r            *bufio.Reader
+	format       FrameFormat
+	decoder      *Decoder
+	buf          []byte
+	maxFrameSize int
+	offset       int64
+	err          *TransitError // the error that stopped the reader
+}
+
+// NewFramedReader returns a FramedReader that reads frames in the
+// given format from r.
+func NewFramedReader(r io.Reader, format FrameFormat) *FramedReader {
+	return &FramedReader{
+		r:            bufio.NewReader(r),
+		format:       format,
+		decoder:      newBytesDecoder(nil),
+		maxFrameSize: DefaultMaxFrameSize,
+	}
+}
+
+// Decoder returns the Decoder that the reader uses for each frame, so
+// that handlers and limits can be set on it.
+func (fr *FramedReader) Decoder() *Decoder {
+	return fr.decoder
+}
+
+// SetMaxFrameSize sets the size of the largest frame the reader will
+// accept. Zero or less means no limit.
+func (fr *FramedReader) SetMaxFrameSize(max int) {
+	fr.maxFrameSize = max
+}
+
+// Decode reads the next frame and decodes the value in it. At the end
+// of the stream it returns io.EOF. Errors are *TransitErrors with
+// offsets counted from the start of the stream, unless the underlying
+// reader failed.
+func (fr *FramedReader) Decode() (interface{}, error) {
+	if fr.err != nil {
+		c := *fr.err
+		return nil, &c
+	}
+
+	start := fr.offset
+	frame, err := fr.readFrame()
+	if err != nil {
+		if te, ok := err.(*TransitError); ok {
+			err = te.at(start)
+			if fr.format != FrameNewline && !errors.Is(te, io.ErrUnexpectedEOF) {
+				fr.err = err.(*TransitError)
+			}
+		}
+		return nil, err
+	}
+
+	fr.decoder.scan.resetBytes(frame)
+	value, err := fr.decoder.decodeChunk()
+	fr.decoder.scan.resetBytes(nil)
+
+	if te, ok := err.(*TransitError); ok {
+		err = te.at(te.Offset + fr.offset - int64(len(frame)))
+	}
+	return value, err
+}
+
+// readFrame reads the next frame, without its prefix or newline.
+func (fr *FramedReader) readFrame() ([]byte, error) {
+	if fr.format == FrameNewline {
+		return fr.readLine()
+	}
+
+	size, err := fr.readLength()
+	if err != nil {
+		return nil, err
+	}
+	if fr.maxFrameSize > 0 && size > uint64(fr.maxFrameSize) {
+		return nil, frameTooLarge(fr.maxFrameSize, size)
+	}
+
+	if uint64(cap(fr.buf)) < size {
+		fr.buf = make([]byte, size)
+	}
+	fr.buf = fr.buf[:size]
+	n, err := io.ReadFull(fr.r, fr.buf)
+	fr.offset += int64(n)
+	if err != nil {
+		return nil, unexpectedFrameEnd(err)
+	}
+	return fr.buf, nil
+}
+
+// readLength reads the length prefix of a frame.
+func (fr *FramedReader) readLength() (uint64, error) {
+	if fr.format == FrameFixed32 {
+		var prefix [4]byte
+		n, err := io.ReadFull(fr.r, prefix[:])
+		fr.offset += int64(n)
+		if err != nil {
+			if err == io.EOF {
+				return 0, err
+			}
+			return 0, unexpectedFrameEnd(err)
+		}
+		return uint64(binary.BigEndian.Uint32(prefix[:])), nil
+	}
+
+	counter := byteCounter{r: fr.r}
+	size, err := binary.ReadUvarint(&counter)
+	fr.offset += counter.n
+	switch {
+	case err == nil:
+		return size, nil
+	case err == io.EOF || counter.failed:
+		return 0, err
+	case err == io.ErrUnexpectedEOF:
+		return 0, unexpectedFrameEnd(err)
+	}
+	return 0, newSyntaxError("Bad frame length", nil, err)
+}
+
+// readLine reads the next line that is not blank.
+func (fr *FramedReader) readLine() ([]byte, error) {
+	for {
+		fr.buf = fr.buf[:0]
+		tooLong := false
+
+		for {
+			part, err := fr.r.ReadSlice('\n')
+			fr.offset += int64(len(part))
+			if !tooLong {
+				fr.buf = append(fr.buf, part...)
+				if fr.maxFrameSize > 0 && len(bytes.TrimRight(fr.buf, "\r\n")) > fr.maxFrameSize {
+					tooLong = true
+				}
+			}
+			if err == bufio.ErrBufferFull {
+				continue
+			}
+			if err == io.EOF && (len(fr.buf) > 0 || tooLong) {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			break
+		}
+
+		if tooLong {
+			return nil, frameTooLarge(fr.maxFrameSize, uint64(len(fr.buf)))
+		}
+		if len(bytes.TrimSpace(fr.buf)) > 0 {
+			return fr.buf, nil
+		}
+	}
+}
+
+func unexpectedFrameEnd(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return newSyntaxError("Unexpected end of input in frame", nil, io.ErrUnexpectedEOF)
+	}
+	return err
+}
+
+// byteCounter is an io.ByteReader that counts the bytes read through
+// it, and notes whether the underlying reader failed.
+type byteCounter struct {
+	r      io.ByteReader
+	n      int64
+	failed bool
+}
+
+func (bc *byteCounter) ReadByte() (byte, error) {
+	b, err := bc.r.ReadByte()
+	if err == nil {
+		bc.n++
+	} else if err != io.EOF {
+		bc.failed = true
+	}
+	return b, err
+}
+
+// FrameSplit returns a bufio.SplitFunc for frames in the given format,
+// for use with bufio.Scanner or ParallelDecoder.SetSplit.
+func FrameSplit(format FrameFormat) bufio.SplitFunc {
+	if format == FrameNewline {
+		return bufio.ScanLines
+	}
+
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		if atEOF && len(data) == 0 {
+			return 0, nil, nil
+		}
+
+		var size uint64
+		var n int
+		if format == FrameFixed32 {
+			if len(data) >= 4 {
+				size, n = uint64(binary.BigEndian.Uint32(data)), 4
+			}
+		} else {
+			size, n = binary.Uvarint(data)
+			if n < 0 {
+				return 0, nil, newSyntaxError("Bad frame length", nil, nil)
+			}
+		}
+
+		if n > 0 && uint64(len(data)-n) >= size {
+			end := n + int(size)
+			return end, data[n:end], nil
+		}
+		if atEOF {
+			return 0, nil, unexpectedFrameEnd(io.ErrUnexpectedEOF)
+		}
+		return 0, nil, nil
+	}
+}
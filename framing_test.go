@@ -0,0 +1,230 @@
+// Copyright 2016 Russ Olsen. All Rights Reserved.
+//
+// This code is a Go port of the Java version created and maintained by Cognitect, therefore:
+//
+// Copyright 2014 Cognitect. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS-IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transit
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var frameFormats = []FrameFormat{FrameVarint, FrameFixed32, FrameNewline}
+
+func frameTestValues() []interface{} {
+	return []interface{}{
+		"plain",
+		[]interface{}{Keyword("abcd"), Keyword("abcd"), "line\nbreak"},
+		map[interface{}]interface{}{Keyword("n"): int64(1)},
+		strings.Repeat("x", 300),
+		nil,
+	}
+}
+
+func TestFramedRoundTrip(t *testing.T) {
+	for _, format := range frameFormats {
+		var wc writeCounter
+		var buf bytes.Buffer
+		fw := NewFramedWriter(io.MultiWriter(&buf, &wc), format)
+
+		values := frameTestValues()
+		for _, value := range values {
+			if err := fw.Encode(value); err != nil {
+				t.Fatalf("Unexpected error writing format %v: %v", format, err)
+			}
+		}
+		if wc.writes != len(values) {
+			t.Errorf("Expected one write per frame in format %v, got %v", format, wc.writes)
+		}
+
+		fr := NewFramedReader(&buf, format)
+		for _, expected := range values {
+			value, err := fr.Decode()
+			if err != nil {
+				t.Fatalf("Unexpected error reading format %v: %v", format, err)
+			}
+			if !reflect.DeepEqual(value, expected) {
+				t.Errorf("Expected %v in format %v, got %v", expected, format, value)
+			}
+		}
+		if _, err := fr.Decode(); err != io.EOF {
+			t.Errorf("Expected the end of the input in format %v, got %v", format, err)
+		}
+	}
+}
+
+func TestFramedOverPipe(t *testing.T) {
+	for _, format := range frameFormats {
+		client, server := net.Pipe()
+
+		go func() {
+			fw := NewFramedWriter(server, format)
+			for i := 0; i < 3; i++ {
+				fw.Encode([]interface{}{i, Keyword("abcd"), Keyword("abcd")})
+			}
+			server.Close()
+		}()
+
+		var values []interface{}
+		fr := NewFramedReader(client, format)
+		for {
+			value, err := fr.Decode()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error in format %v: %v", format, err)
+			}
+			values = append(values, value)
+		}
+		client.Close()
+
+		if len(values) != 3 || !reflect.DeepEqual(values[2], []interface{}{int64(2), Keyword("abcd"), Keyword("abcd")}) {
+			t.Errorf("Expected three values in format %v, got %v", format, values)
+		}
+	}
+}
+
+func TestFramedMaxFrameSize(t *testing.T) {
+	for _, format := range frameFormats {
+		var buf bytes.Buffer
+		fw := NewFramedWriter(&buf, format)
+
+		fw.SetMaxFrameSize(10)
+		if err := fw.Encode(strings.Repeat("x", 20)); !errors.Is(err, KindLimit) || buf.Len() != 0 {
+			t.Errorf("Expected a limit error and no output in format %v, got %v", format, err)
+		}
+
+		fw.SetMaxFrameSize(0)
+		for _, value := range []interface{}{"a", strings.Repeat("x", 5000), "b"} {
+			fw.Encode(value)
+		}
+
+		fr := NewFramedReader(&buf, format)
+		fr.SetMaxFrameSize(100)
+		var values []interface{}
+		var errs []error
+		for i := 0; i < 4; i++ {
+			value, err := fr.Decode()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				errs = append(errs, err)
+			} else {
+				values = append(values, value)
+			}
+		}
+
+		if format == FrameNewline {
+			if !reflect.DeepEqual(values, []interface{}{"a", "b"}) || len(errs) != 1 || !errors.Is(errs[0], KindLimit) {
+				t.Errorf("Expected to skip the large frame in format %v, got %v %v", format, values, errs)
+			}
+			continue
+		}
+
+		// A length prefix is not trusted: the reader stops where it is.
+		if !reflect.DeepEqual(values, []interface{}{"a"}) || len(errs) != 3 {
+			t.Fatalf("Expected to stop at the large frame in format %v, got %v %v", format, values, errs)
+		}
+		for _, err := range errs {
+			VerifyError(t, err, KindLimit, "[]")
+		}
+		if errs[1] == errs[2] {
+			t.Errorf("Expected each Decode to return its own copy of the error in format %v", format)
+		}
+		if buf.Len() == 0 {
+			t.Errorf("Expected the large frame to be left unread in format %v", format)
+		}
+	}
+}
+
+func TestFramedBadFrames(t *testing.T) {
+	tests := []struct {
+		format FrameFormat
+		input  string
+	}{
+		{FrameVarint, "\x05[1,2"},
+		{FrameVarint, "\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\x01"},
+		{FrameFixed32, "\x00\x00"},
+		{FrameFixed32, "\x00\x00\x00\x05[1,2"},
+	}
+
+	for _, test := range tests {
+		fr := NewFramedReader(strings.NewReader(test.input), test.format)
+		if _, err := fr.Decode(); !errors.Is(err, KindSyntax) {
+			t.Errorf("Expected a syntax error reading %q, got %v", test.input, err)
+		}
+	}
+
+	// After a malformed length prefix there is no telling where the
+	// next frame starts, so the reader stops.
+	fr := NewFramedReader(strings.NewReader(strings.Repeat("\xff", 11)+"\x01\x011"), FrameVarint)
+	for i := 0; i < 3; i++ {
+		if _, err := fr.Decode(); !errors.Is(err, KindSyntax) || err.(*TransitError).Offset != 0 {
+			t.Errorf("Expected the bad prefix error every time, got %v", err)
+		}
+	}
+
+	// A frame that does not decode is skipped.
+	fr = NewFramedReader(strings.NewReader("[1,,2]\n\n3 4\n5"), FrameNewline)
+	var values []interface{}
+	var offsets []int64
+	for {
+		value, err := fr.Decode()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			offsets = append(offsets, err.(*TransitError).Offset)
+		} else {
+			values = append(values, value)
+		}
+	}
+	if !reflect.DeepEqual(values, []interface{}{int64(5)}) || !reflect.DeepEqual(offsets, []int64{3, 10}) {
+		t.Errorf("Expected errors at 3 and 10 and then 5, got %v %v", offsets, values)
+	}
+}
+
+func TestFrameSplit(t *testing.T) {
+	for _, format := range frameFormats {
+		var buf bytes.Buffer
+		fw := NewFramedWriter(&buf, format)
+		for _, value := range frameTestValues() {
+			fw.Encode(value)
+		}
+
+		pd := NewParallelDecoder(&buf, 2)
+		pd.SetSplit(FrameSplit(format))
+
+		var values []interface{}
+		for value, err := range pd.All() {
+			if err != nil {
+				t.Fatalf("Unexpected error in format %v: %v", format, err)
+			}
+			values = append(values, value)
+		}
+		if !reflect.DeepEqual(values, frameTestValues()) {
+			t.Errorf("Expected %v in format %v, got %v", frameTestValues(), format, values)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright 2016 Russ Olsen. All Rights Reserved.
+//
+// This code is a Go port of the Java version created and maintained by Cognitect, therefore:
+//
+// Copyright 2014 Cognitect. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS-IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// accept is the Accept header the client sends. It reads either kind
+// of transit+json.
+const accept = ContentTypeJSON + ", " + ContentTypeJSONVerbose
+
+// maxErrorText is how much of a response that is not transit the
+// client reads for the message of an Error.
+const maxErrorText = 1024
+
+// Client sends values to and reads values from transit services.
+type Client struct {
+	client *http.Client
+	codec  *Codec
+	format Format
+}
+
+// NewClient returns a Client that sends its requests with client,
+// or http.DefaultClient if client is nil, and uses DefaultCodec.
+func NewClient(client *http.Client) *Client {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Client{client: client, codec: DefaultCodec, format: FormatJSON}
+}
+
+// SetCodec sets the Codec for request and response bodies.
+func (c *Client) SetCodec(codec *Codec) {
+	c.codec = codec
+}
+
+// SetVerbose controls whether request bodies are sent as verbose
+// transit+json.
+func (c *Client) SetVerbose(verbose bool) {
+	if verbose {
+		c.format = FormatJSONVerbose
+	} else {
+		c.format = FormatJSON
+	}
+}
+
+// Do sends a request with in, unless it is nil, as its body and
+// decodes the response body into the value out points to, as
+// Decoder.DecodeInto does. A nil out discards the response. A
+// response with a status of 300 or more is returned as an *Error
+// holding the status and the message the server sent.
+func (c *Client) Do(ctx context.Context, method, url string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		b, err := c.codec.encode(in, c.format)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", accept)
+	if in != nil {
+		req.Header.Set("Content-Type", c.format.ContentType())
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return c.readError(resp)
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+
+	if _, err := ParseContentType(resp.Header.Get("Content-Type")); err != nil {
+		return err
+	}
+	return c.codec.newDecoder(resp.Body, c.codec.limits).DecodeInto(out)
+}
+
+// Get sends a GET request to url and decodes the response into out.
+func (c *Client) Get(ctx context.Context, url string, out interface{}) error {
+	return c.Do(ctx, http.MethodGet, url, nil, out)
+}
+
+// Post sends in to url in a POST request and decodes the response
+// into out.
+func (c *Client) Post(ctx context.Context, url string, in, out interface{}) error {
+	return c.Do(ctx, http.MethodPost, url, in, out)
+}
+
+// readError turns an error response into an *Error. The message is
+// the one sent by WriteError if there is one, otherwise the start of
+// the body or the standard status text.
+func (c *Client) readError(resp *http.Response) error {
+	he := &Error{Status: resp.StatusCode, Message: http.StatusText(resp.StatusCode)}
+
+	contentType := resp.Header.Get("Content-Type")
+	if _, err := ParseContentType(contentType); err == nil && contentType != "" {
+		x, err := c.codec.newDecoder(resp.Body, c.codec.limits).Decode()
+		if m, ok := x.(map[interface{}]interface{}); ok && err == nil {
+			if msg, ok := m[errorKey].(string); ok {
+				he.Message = msg
+			}
+		}
+		return he
+	}
+
+	text, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorText))
+	if msg := strings.TrimSpace(string(text)); msg != "" {
+		he.Message = msg
+	}
+	return he
+}
@@ -0,0 +1,210 @@
+// Copyright 2016 Russ Olsen. All Rights Reserved.
+//
+// This code is a Go port of the Java version created and maintained by Cognitect, therefore:
+//
+// Copyright 2014 Cognitect. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS-IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package http reads and writes transit over HTTP. It picks the format
+// of a response from the request's Accept header, reads request
+// bodies according to their Content-Type and maps failures to HTTP
+// status codes, on the server with Handle, ReadRequest and
+// WriteResponse and on the client with Client.
+//
+// The media types are
+//
+//	application/transit+json          transit, written with caching
+//	application/transit+json;verbose  transit, written without caching
+//	application/transit+msgpack       transit in msgpack
+//
+// This implementation has no msgpack support. Requests with a msgpack
+// body get a 415 Unsupported Media Type, and requests that will only
+// accept msgpack get a 406 Not Acceptable.
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// The transit media types.
+const (
+	ContentTypeJSON        = "application/transit+json"
+	ContentTypeJSONVerbose = "application/transit+json;verbose"
+	ContentTypeMsgpack     = "application/transit+msgpack"
+)
+
+// Format is one of the transit wire formats.
+type Format int
+
+const (
+	FormatJSON Format = iota
+	FormatJSONVerbose
+	FormatMsgpack
+)
+
+// ContentType returns the media type for f.
+func (f Format) ContentType() string {
+	switch f {
+	case FormatJSONVerbose:
+		return ContentTypeJSONVerbose
+	case FormatMsgpack:
+		return ContentTypeMsgpack
+	default:
+		return ContentTypeJSON
+	}
+}
+
+// Verbose reports whether f is written without caching.
+func (f Format) Verbose() bool {
+	return f == FormatJSONVerbose
+}
+
+func (f Format) String() string {
+	return f.ContentType()
+}
+
+// Error is an error with the HTTP status that goes with it. Handlers
+// return one to send a particular status and message. Client returns
+// one for responses with an error status.
+type Error struct {
+	Status  int    // The HTTP status code.
+	Message string // The message sent to, or received from, the other side.
+	Err     error  // The underlying error, if any.
+}
+
+// NewError returns an Error with the given status and message.
+func NewError(status int, format string, args ...interface{}) *Error {
+	return &Error{Status: status, Message: fmt.Sprintf(format, args...)}
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("%d %s", e.Status, e.Message)
+	if e.Err != nil {
+		msg = msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+// Unwrap returns the underlying error, if any.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// mediaRange is one entry of an Accept or Content-Type header.
+type mediaRange struct {
+	mediaType string
+	verbose   bool
+	q         float64
+}
+
+// parseMediaRange parses a media type and its parameters. The
+// verbose parameter of transit+json has no value, which is why this
+// does not use mime.ParseMediaType.
+func parseMediaRange(s string) mediaRange {
+	params := strings.Split(s, ";")
+	mr := mediaRange{mediaType: strings.ToLower(strings.TrimSpace(params[0])), q: 1}
+	for _, param := range params[1:] {
+		name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "verbose":
+			mr.verbose = value == "" || value == "true"
+		case "q":
+			if q, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+				mr.q = q
+			}
+		}
+	}
+	return mr
+}
+
+// ParseContentType returns the format of a request or response body
+// with the given Content-Type. An empty Content-Type is taken to be
+// transit+json. The error is an *Error with status 415.
+func ParseContentType(contentType string) (Format, error) {
+	if strings.TrimSpace(contentType) == "" {
+		return FormatJSON, nil
+	}
+
+	mr := parseMediaRange(contentType)
+	switch mr.mediaType {
+	case ContentTypeJSON:
+		if mr.verbose {
+			return FormatJSONVerbose, nil
+		}
+		return FormatJSON, nil
+	case ContentTypeMsgpack:
+		return FormatMsgpack, NewError(http.StatusUnsupportedMediaType, "%s is not supported", ContentTypeMsgpack)
+	}
+	return FormatJSON, NewError(http.StatusUnsupportedMediaType, "Expected %s, got %s", ContentTypeJSON, contentType)
+}
+
+// offered lists the formats that Negotiate can pick, most preferred
+// first.
+var offered = []Format{FormatJSON, FormatJSONVerbose}
+
+// specificity returns how closely mr matches format f, from 0 for
+// */* to 3 for an exact match, or -1 if it does not match at all.
+func (mr mediaRange) specificity(f Format) int {
+	switch mr.mediaType {
+	case "*/*":
+		return 0
+	case "application/*":
+		return 1
+	case ContentTypeJSON:
+		switch {
+		case mr.verbose == f.Verbose():
+			return 3
+		case !mr.verbose:
+			return 2
+		}
+	}
+	return -1
+}
+
+// Negotiate returns the format to answer a request with the given
+// Accept header in. Each format gets the quality of the most specific
+// range that matches it, and the format with the highest quality
+// wins; on a tie, plain transit+json wins. An empty header accepts
+// anything. The error is an *Error with status 406.
+func Negotiate(accept string) (Format, error) {
+	if strings.TrimSpace(accept) == "" {
+		return FormatJSON, nil
+	}
+
+	var ranges []mediaRange
+	for _, s := range strings.Split(accept, ",") {
+		ranges = append(ranges, parseMediaRange(s))
+	}
+
+	best, bestQ := FormatJSON, 0.0
+	for _, f := range offered {
+		q, specificity := 0.0, -1
+		for _, mr := range ranges {
+			if s := mr.specificity(f); s > specificity {
+				q, specificity = mr.q, s
+			}
+		}
+		if q > bestQ {
+			best, bestQ = f, q
+		}
+	}
+
+	if bestQ == 0 {
+		return FormatJSON, NewError(http.StatusNotAcceptable, "Can only send %s or %s", ContentTypeJSON, ContentTypeJSONVerbose)
+	}
+	return best, nil
+}
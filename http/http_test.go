@@ -0,0 +1,298 @@
+// Copyright 2016 Russ Olsen. All Rights Reserved.
+//
+// This code is a Go port of the Java version created and maintained by Cognitect, therefore:
+//
+// Copyright 2014 Cognitect. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS-IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"context"
+	"errors"
+	"github.com/russolsen/transit"
+	"github.com/shopspring/decimal"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseContentType(t *testing.T) {
+	tests := []struct {
+		contentType string
+		format      Format
+		status      int
+	}{
+		{"", FormatJSON, 0},
+		{"application/transit+json", FormatJSON, 0},
+		{"Application/Transit+JSON; charset=utf-8", FormatJSON, 0},
+		{"application/transit+json;verbose", FormatJSONVerbose, 0},
+		{"application/transit+json; verbose=true", FormatJSONVerbose, 0},
+		{"application/transit+msgpack", FormatMsgpack, http.StatusUnsupportedMediaType},
+		{"application/json", FormatJSON, http.StatusUnsupportedMediaType},
+	}
+
+	for _, test := range tests {
+		f, err := ParseContentType(test.contentType)
+		if f != test.format || status(err) != test.status {
+			t.Errorf("%q: expected %v and status %d, got %v, %v", test.contentType, test.format, test.status, f, err)
+		}
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		accept string
+		format Format
+		status int
+	}{
+		{"", FormatJSON, 0},
+		{"*/*", FormatJSON, 0},
+		{"text/html, application/xml;q=0.9, */*;q=0.8", FormatJSON, 0},
+		{"application/transit+json", FormatJSON, 0},
+		{"application/transit+json;verbose", FormatJSONVerbose, 0},
+		{"application/transit+json;q=0.5, application/transit+json;verbose", FormatJSONVerbose, 0},
+		{"application/transit+json;verbose, application/transit+json", FormatJSON, 0},
+		{"application/*;q=0.2, application/transit+json;verbose;q=0.1", FormatJSON, 0},
+		{"application/transit+msgpack, application/transit+json;q=0.1", FormatJSON, 0},
+		{"application/transit+msgpack", FormatJSON, http.StatusNotAcceptable},
+		{"application/transit+json;q=0", FormatJSON, http.StatusNotAcceptable},
+		{"text/html", FormatJSON, http.StatusNotAcceptable},
+	}
+
+	for _, test := range tests {
+		f, err := Negotiate(test.accept)
+		if f != test.format || status(err) != test.status {
+			t.Errorf("%q: expected %v and status %d, got %v, %v", test.accept, test.format, test.status, f, err)
+		}
+	}
+}
+
+// status returns the status of an *Error, or 0 for nil.
+func status(err error) int {
+	var he *Error
+	if errors.As(err, &he) {
+		return he.Status
+	}
+	if err != nil {
+		return -1
+	}
+	return 0
+}
+
+type Order struct {
+	Item     transit.Keyword `transit:"item"`
+	Quantity int             `transit:"quantity"`
+	Price    decimal.Decimal `transit:"price"`
+	Options  *transit.Set    `transit:"options"`
+}
+
+type Invoice struct {
+	Item  transit.Keyword `transit:"item"`
+	Total decimal.Decimal `transit:"total"`
+}
+
+func newOrderServer(t *testing.T) (*httptest.Server, *int) {
+	calls := new(int)
+	handler := Handle(func(ctx context.Context, order Order) (Invoice, error) {
+		*calls++
+		switch {
+		case order.Quantity < 0:
+			return Invoice{}, NewError(http.StatusUnprocessableEntity, "Cannot order %d", order.Quantity)
+		case order.Item == "secret":
+			return Invoice{}, errors.New("database password is hunter2")
+		}
+		return Invoice{Item: order.Item, Total: order.Price.Mul(decimal.NewFromInt(int64(order.Quantity)))}, nil
+	})
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return server, calls
+}
+
+func TestHandle(t *testing.T) {
+	server, _ := newOrderServer(t)
+
+	for _, verbose := range []bool{false, true} {
+		client := NewClient(server.Client())
+		client.SetVerbose(verbose)
+
+		order := Order{
+			Item:     transit.Keyword("widget"),
+			Quantity: 3,
+			Price:    decimal.RequireFromString("2.50"),
+			Options:  transit.MakeSet(transit.Keyword("gift-wrap")),
+		}
+		var invoice Invoice
+		if err := client.Post(context.Background(), server.URL, order, &invoice); err != nil {
+			t.Fatal(err)
+		}
+		if invoice.Item != order.Item || !invoice.Total.Equal(decimal.RequireFromString("7.5")) {
+			t.Errorf("Expected 7.5 for widgets, got %+v", invoice)
+		}
+	}
+}
+
+func TestHandleErrors(t *testing.T) {
+	server, calls := newOrderServer(t)
+	client := NewClient(server.Client())
+	ctx := context.Background()
+
+	err := client.Post(ctx, server.URL, Order{Quantity: -1}, &Invoice{})
+	if status(err) != http.StatusUnprocessableEntity || err.(*Error).Message != "Cannot order -1" {
+		t.Errorf("Expected a 422 from the handler, got %v", err)
+	}
+
+	err = client.Post(ctx, server.URL, Order{Item: "secret"}, &Invoice{})
+	if status(err) != http.StatusInternalServerError || strings.Contains(err.Error(), "hunter2") {
+		t.Errorf("Expected a 500 without details, got %v", err)
+	}
+
+	err = client.Post(ctx, server.URL, map[transit.Keyword]interface{}{"quantity": "many"}, &Invoice{})
+	if status(err) != http.StatusBadRequest {
+		t.Errorf("Expected a 400 for a bad order, got %v", err)
+	}
+
+	// Requests the server cannot handle never reach the function.
+	before := *calls
+	tests := []struct {
+		contentType, accept, body string
+		status                    int
+	}{
+		{ContentTypeMsgpack, "", "\x80", http.StatusUnsupportedMediaType},
+		{ContentTypeJSON, ContentTypeMsgpack, `["^ "]`, http.StatusNotAcceptable},
+		{ContentTypeJSON, "", `["^ ","~:quantity"`, http.StatusBadRequest},
+		{ContentTypeJSON, "", `"~"`, http.StatusBadRequest},
+		{ContentTypeJSON, "", `["~#t",5]`, http.StatusBadRequest},
+		{ContentTypeJSON, "", `["~#ratio",[1,0]]`, http.StatusBadRequest},
+		{ContentTypeJSON, "", `"` + strings.Repeat("x", 1<<25) + `"`, http.StatusRequestEntityTooLarge},
+	}
+	for _, test := range tests {
+		req, _ := http.NewRequest(http.MethodPost, server.URL, strings.NewReader(test.body))
+		req.Header.Set("Content-Type", test.contentType)
+		req.Header.Set("Accept", test.accept)
+		resp, err := server.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != test.status {
+			t.Errorf("%s, %s: expected %d, got %d", test.contentType, test.accept, test.status, resp.StatusCode)
+		}
+		if ct := resp.Header.Get("Content-Type"); ct != ContentTypeJSON {
+			t.Errorf("Expected errors in %s, got %s", ContentTypeJSON, ct)
+		}
+	}
+	if *calls != before {
+		t.Errorf("Expected no calls, got %d", *calls-before)
+	}
+}
+
+func TestHandleDecoderPanic(t *testing.T) {
+	c := NewCodec()
+	c.ConfigureDecoder(func(d *transit.Decoder) {
+		d.AddHandler("boom", func(d transit.Decoder, x interface{}) (interface{}, error) {
+			panic("boom")
+		})
+	})
+	handler := HandleWith(c, func(ctx context.Context, x interface{}) (interface{}, error) {
+		return x, nil
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`["~#boom",1]`))
+	req.Header.Set("Content-Type", ContentTypeJSON)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected a 400, got %d", w.Code)
+	}
+	body, err := transit.DecodeBytes(w.Body.Bytes())
+	expected := map[interface{}]interface{}{transit.Keyword("error"): "Malformed body"}
+	if err != nil || !reflect.DeepEqual(body, expected) {
+		t.Errorf("Expected %v, got %v %v", expected, body, err)
+	}
+}
+
+func TestWriteResponse(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept", ContentTypeJSONVerbose)
+	w := httptest.NewRecorder()
+
+	if err := WriteResponse(w, req, http.StatusCreated, Invoice{Item: "widget"}); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusCreated {
+		t.Errorf("Expected 201, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != ContentTypeJSONVerbose {
+		t.Errorf("Expected %s, got %s", ContentTypeJSONVerbose, ct)
+	}
+	if vary := w.Header().Get("Vary"); vary != "Accept" {
+		t.Errorf("Expected Vary: Accept, got %s", vary)
+	}
+	if body := w.Body.String(); !strings.Contains(body, `"~:item":"~:widget"`) {
+		t.Errorf("Expected a verbose map, got %s", body)
+	}
+
+	w = httptest.NewRecorder()
+	err := WriteResponse(w, req, http.StatusOK, make(chan int))
+	if status(err) != http.StatusInternalServerError || w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected a 500 for a value that cannot be encoded, got %v, %d", err, w.Code)
+	}
+}
+
+func TestClient(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/plain", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "upstream is down", http.StatusBadGateway)
+	})
+	mux.HandleFunc("/echo", func(w http.ResponseWriter, r *http.Request) {
+		var x interface{}
+		if err := ReadRequest(r, &x); err != nil {
+			WriteError(w, r, err)
+			return
+		}
+		WriteResponse(w, r, http.StatusOK, []interface{}{r.Header.Get("Accept"), x})
+	})
+	mux.Handle("/none", Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})))
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := NewClient(server.Client())
+	ctx := context.Background()
+
+	err := client.Get(ctx, server.URL+"/plain", nil)
+	if status(err) != http.StatusBadGateway || err.(*Error).Message != "upstream is down" {
+		t.Errorf("Expected a 502 with the text of the body, got %v", err)
+	}
+
+	var echo []interface{}
+	if err := client.Post(ctx, server.URL+"/echo", transit.MakeSet(1), &echo); err != nil {
+		t.Fatal(err)
+	}
+	expected := []interface{}{accept, transit.MakeSet(int64(1))}
+	if !reflect.DeepEqual(echo, expected) {
+		t.Errorf("Expected %v, got %v", expected, echo)
+	}
+
+	if err := client.Do(ctx, http.MethodDelete, server.URL+"/none", nil, &echo); err != nil {
+		t.Errorf("Expected no content, got %v", err)
+	}
+}
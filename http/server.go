@@ -0,0 +1,253 @@
+// Copyright 2016 Russ Olsen. All Rights Reserved.
+//
+// This code is a Go port of the Java version created and maintained by Cognitect, therefore:
+//
+// Copyright 2014 Cognitect. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS-IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/russolsen/transit"
+	"io"
+	"net/http"
+)
+
+// Codec makes the encoders and decoders for request and response
+// bodies. Encoders have struct mapping on, so that typed values are
+// written as maps, and decoders have limits set, since request bodies
+// come from outside. Configure the encoders and decoders further, say
+// with handlers for extension types, with ConfigureEncoder and
+// ConfigureDecoder.
+type Codec struct {
+	encoderSetup func(*transit.Encoder)
+	decoderSetup func(*transit.Decoder)
+	limits       transit.Limits
+}
+
+// DefaultCodec is the Codec used by the package level functions.
+var DefaultCodec = NewCodec()
+
+// NewCodec returns a Codec whose decoders use transit.DefaultLimits.
+func NewCodec() *Codec {
+	return &Codec{limits: transit.DefaultLimits}
+}
+
+// ConfigureEncoder sets a function that is called on each new encoder.
+func (c *Codec) ConfigureEncoder(setup func(*transit.Encoder)) {
+	c.encoderSetup = setup
+}
+
+// ConfigureDecoder sets a function that is called on each new decoder.
+func (c *Codec) ConfigureDecoder(setup func(*transit.Decoder)) {
+	c.decoderSetup = setup
+}
+
+// SetLimits sets the limits for the decoders.
+func (c *Codec) SetLimits(limits transit.Limits) {
+	c.limits = limits
+}
+
+func (c *Codec) newEncoder(w io.Writer, f Format) *transit.Encoder {
+	e := transit.NewEncoder(w, f.Verbose())
+	e.SetStructMapping(true)
+	if c.encoderSetup != nil {
+		c.encoderSetup(e)
+	}
+	return e
+}
+
+func (c *Codec) newDecoder(r io.Reader, limits transit.Limits) *transit.Decoder {
+	d := transit.NewDecoder(r)
+	d.SetLimits(limits)
+	if c.decoderSetup != nil {
+		c.decoderSetup(d)
+	}
+	return d
+}
+
+// encode returns v encoded in format f.
+func (c *Codec) encode(v interface{}, f Format) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := c.newEncoder(&buf, f).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decode decodes body into the value v points to, turning failures
+// into Errors with a suitable status. A read handler that panics on a
+// bad body is a malformed body too, rather than a dropped connection.
+func (c *Codec) decode(body io.Reader, v interface{}) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			msg := fmt.Sprintf("Decoding panicked: %v", p)
+			te := &transit.TransitError{Kind: transit.KindSyntax, Message: msg, Source: p}
+			err = &Error{Status: http.StatusBadRequest, Message: "Malformed body", Err: te}
+		}
+	}()
+
+	err = c.newDecoder(body, c.limits).DecodeInto(v)
+
+	var tooLarge *http.MaxBytesError
+	switch {
+	case err == nil:
+		return nil
+	case err == io.EOF:
+		return NewError(http.StatusBadRequest, "Missing body")
+	case errors.Is(err, transit.KindLimit), errors.As(err, &tooLarge):
+		return &Error{Status: http.StatusRequestEntityTooLarge, Message: "Body too large", Err: err}
+	}
+	return &Error{Status: http.StatusBadRequest, Message: "Malformed body", Err: err}
+}
+
+// ReadRequest decodes the body of r into the value v points to, as
+// Decoder.DecodeInto does. The error is an *Error whose status is
+// 415 for an unsupported Content-Type, 413 if the body goes past the
+// limits and 400 for anything else.
+func (c *Codec) ReadRequest(r *http.Request, v interface{}) error {
+	if _, err := ParseContentType(r.Header.Get("Content-Type")); err != nil {
+		return err
+	}
+	return c.decode(r.Body, v)
+}
+
+// WriteResponse writes v with the given status, in the format that
+// the Accept header of r asks for. The body is encoded before
+// anything is written, so that an error can still be reported with a
+// status of its own; in that case, or if the Accept header cannot be
+// satisfied, WriteResponse writes the error with WriteError and
+// returns it.
+func (c *Codec) WriteResponse(w http.ResponseWriter, r *http.Request, status int, v interface{}) error {
+	f, err := Negotiate(r.Header.Get("Accept"))
+	if err != nil {
+		c.WriteError(w, r, err)
+		return err
+	}
+
+	body, err := c.encode(v, f)
+	if err != nil {
+		err = &Error{Status: http.StatusInternalServerError, Message: "Cannot encode response", Err: err}
+		c.WriteError(w, r, err)
+		return err
+	}
+
+	writeBody(w, f, status, body)
+	return nil
+}
+
+// WriteError writes err as a map holding a message,
+// {:error "message"}. The status and message come from err if it is
+// an *Error. Other errors are sent as a 500 with the standard text, so
+// that internal details do not leak out.
+func (c *Codec) WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	status, msg := http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError)
+	var he *Error
+	if errors.As(err, &he) {
+		status, msg = he.Status, he.Message
+	}
+
+	// A request that accepts nothing we can send gets plain
+	// transit+json, which is better than nothing.
+	f, _ := Negotiate(r.Header.Get("Accept"))
+	body, encodeErr := c.encode(map[transit.Keyword]interface{}{errorKey: msg}, f)
+	if encodeErr != nil {
+		http.Error(w, msg, status)
+		return
+	}
+	writeBody(w, f, status, body)
+}
+
+var errorKey = transit.Keyword("error")
+
+func writeBody(w http.ResponseWriter, f Format, status int, body []byte) {
+	h := w.Header()
+	h.Set("Content-Type", f.ContentType())
+	h.Add("Vary", "Accept")
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// ReadRequest calls DefaultCodec.ReadRequest.
+func ReadRequest(r *http.Request, v interface{}) error {
+	return DefaultCodec.ReadRequest(r, v)
+}
+
+// WriteResponse calls DefaultCodec.WriteResponse.
+func WriteResponse(w http.ResponseWriter, r *http.Request, status int, v interface{}) error {
+	return DefaultCodec.WriteResponse(w, r, status, v)
+}
+
+// WriteError calls DefaultCodec.WriteError.
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	DefaultCodec.WriteError(w, r, err)
+}
+
+// Handle returns a handler that decodes the request body into an In,
+// calls fn with it and writes what fn returns as the response. A
+// request without a body leaves the In as its zero value. Requests
+// whose Accept or Content-Type cannot be handled are turned away
+// before fn is called. If fn fails, the error is written with
+// WriteError, so return an *Error to pick the status and message.
+func Handle[In, Out any](fn func(ctx context.Context, in In) (Out, error)) http.Handler {
+	return HandleWith(DefaultCodec, fn)
+}
+
+// HandleWith is Handle with a Codec of your own.
+func HandleWith[In, Out any](c *Codec, fn func(ctx context.Context, in In) (Out, error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := Negotiate(r.Header.Get("Accept")); err != nil {
+			c.WriteError(w, r, err)
+			return
+		}
+
+		var in In
+		if r.Body != nil && r.Body != http.NoBody {
+			if err := c.ReadRequest(r, &in); err != nil {
+				c.WriteError(w, r, err)
+				return
+			}
+		}
+
+		out, err := fn(r.Context(), in)
+		if err != nil {
+			c.WriteError(w, r, err)
+			return
+		}
+		c.WriteResponse(w, r, http.StatusOK, out)
+	})
+}
+
+// Middleware turns away requests that next could not answer in
+// transit: those with a body in a format other than transit+json get
+// a 415, and those that do not accept transit+json get a 406.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := Negotiate(r.Header.Get("Accept")); err != nil {
+			WriteError(w, r, err)
+			return
+		}
+		if r.Body != nil && r.Body != http.NoBody {
+			if _, err := ParseContentType(r.Header.Get("Content-Type")); err != nil {
+				WriteError(w, r, err)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
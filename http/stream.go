@@ -0,0 +1,354 @@
+// Copyright 2016 Russ Olsen. All Rights Reserved.
+//
+// This code is a Go port of the Java version created and maintained by Cognitect, therefore:
+//
+// Copyright 2014 Cognitect. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS-IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"github.com/russolsen/transit"
+	"io"
+	"iter"
+	"net/http"
+	"strings"
+)
+
+// ContentTypeEventStream is the media type of Server-Sent Events.
+const ContentTypeEventStream = "text/event-stream"
+
+// StreamFormat is the way a StreamWriter sends its values.
+type StreamFormat int
+
+const (
+	// StreamLines sends each value as a line of transit+json.
+	StreamLines StreamFormat = iota
+
+	// StreamLinesVerbose sends each value as a line of verbose
+	// transit+json.
+	StreamLinesVerbose
+
+	// StreamEvents sends each value as a Server-Sent Event whose
+	// data is the value in transit+json.
+	StreamEvents
+)
+
+// ContentType returns the media type for f.
+func (f StreamFormat) ContentType() string {
+	switch f {
+	case StreamLinesVerbose:
+		return ContentTypeJSONVerbose
+	case StreamEvents:
+		return ContentTypeEventStream
+	default:
+		return ContentTypeJSON
+	}
+}
+
+func (f StreamFormat) format() Format {
+	if f == StreamLinesVerbose {
+		return FormatJSONVerbose
+	}
+	return FormatJSON
+}
+
+// StreamWriter writes a stream of values to an http.ResponseWriter,
+// flushing after each one so that the client sees it at once.
+type StreamWriter struct {
+	w       http.ResponseWriter
+	rc      *http.ResponseController
+	codec   *Codec
+	format  StreamFormat
+	buf     bytes.Buffer
+	started bool
+}
+
+// NewStreamWriter returns a StreamWriter that writes to w in the given
+// format, using c to encode the values.
+func (c *Codec) NewStreamWriter(w http.ResponseWriter, format StreamFormat) *StreamWriter {
+	return &StreamWriter{w: w, rc: http.NewResponseController(w), codec: c, format: format}
+}
+
+// NewStreamWriter calls DefaultCodec.NewStreamWriter.
+func NewStreamWriter(w http.ResponseWriter, format StreamFormat) *StreamWriter {
+	return DefaultCodec.NewStreamWriter(w, format)
+}
+
+// Write writes v and flushes it out to the client. The headers go
+// out with the first value.
+func (sw *StreamWriter) Write(v interface{}) error {
+	return sw.WriteEvent("", "", v)
+}
+
+// WriteEvent writes v as an event with the given type and id, either
+// of which may be empty. Lines have no room for them, so with
+// StreamLines the type and id are ignored. A type or id holding a line
+// break would start a new field, so it is an error and nothing is
+// written.
+func (sw *StreamWriter) WriteEvent(event, id string, v interface{}) error {
+	if sw.format == StreamEvents && strings.ContainsAny(event+id, "\r\n") {
+		return errors.New("Event type and id cannot contain line breaks")
+	}
+
+	body, err := sw.codec.encode(v, sw.format.format())
+	if err != nil {
+		return err
+	}
+
+	sw.buf.Reset()
+	if sw.format == StreamEvents {
+		writeField(&sw.buf, "event", event)
+		writeField(&sw.buf, "id", id)
+		writeField(&sw.buf, "data", string(body))
+	} else {
+		sw.buf.Write(body)
+	}
+	sw.buf.WriteByte('\n')
+
+	if !sw.started {
+		h := sw.w.Header()
+		h.Set("Content-Type", sw.format.ContentType())
+		h.Set("Cache-Control", "no-cache")
+		sw.started = true
+	}
+	if _, err := sw.w.Write(sw.buf.Bytes()); err != nil {
+		return err
+	}
+	return sw.flush()
+}
+
+// writeField writes one line of an event, unless value is empty.
+// Transit+json never holds a raw newline, and WriteEvent turns away
+// types and ids that do, so neither do the values.
+func writeField(buf *bytes.Buffer, name, value string) {
+	if value == "" {
+		return
+	}
+	buf.WriteString(name)
+	buf.WriteString(": ")
+	buf.WriteString(value)
+	buf.WriteByte('\n')
+}
+
+// flush sends what has been written to the client. Writers that
+// cannot flush are left to send it when they will.
+func (sw *StreamWriter) flush() error {
+	if err := sw.rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	return nil
+}
+
+// Stream writes each of values to w as it comes, as Server-Sent
+// Events if the Accept header of r asks for text/event-stream and as
+// lines of transit+json otherwise. It stops, returning the context's
+// error, once the client goes away.
+func Stream[T any](w http.ResponseWriter, r *http.Request, values iter.Seq[T]) error {
+	return StreamWith(DefaultCodec, w, r, values)
+}
+
+// StreamWith is Stream with a Codec of your own.
+func StreamWith[T any](c *Codec, w http.ResponseWriter, r *http.Request, values iter.Seq[T]) error {
+	format := StreamEvents
+	if !acceptsEvents(r.Header.Get("Accept")) {
+		f, err := Negotiate(r.Header.Get("Accept"))
+		if err != nil {
+			c.WriteError(w, r, err)
+			return err
+		}
+		format = StreamLines
+		if f.Verbose() {
+			format = StreamLinesVerbose
+		}
+	}
+
+	sw := c.NewStreamWriter(w, format)
+	for v := range values {
+		if err := r.Context().Err(); err != nil {
+			return err
+		}
+		if err := sw.Write(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// acceptsEvents reports whether an Accept header names
+// text/event-stream.
+func acceptsEvents(accept string) bool {
+	for _, s := range strings.Split(accept, ",") {
+		if mr := parseMediaRange(s); mr.mediaType == ContentTypeEventStream && mr.q > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// StreamReader reads the values sent by a StreamWriter, in either
+// format.
+type StreamReader struct {
+	body   io.ReadCloser
+	codec  *Codec
+	dec    *transit.Decoder // Lines.
+	events *bufio.Scanner   // Server-Sent Events.
+	event  string
+	id     string
+}
+
+// NewStreamReader returns a StreamReader for the body of resp. The
+// format comes from the response's Content-Type.
+func (c *Codec) NewStreamReader(resp *http.Response) (*StreamReader, error) {
+	sr := &StreamReader{body: resp.Body, codec: c}
+
+	if parseMediaRange(resp.Header.Get("Content-Type")).mediaType == ContentTypeEventStream {
+		sr.events = bufio.NewScanner(resp.Body)
+		sr.events.Buffer(nil, transit.DefaultMaxFrameSize)
+		return sr, nil
+	}
+
+	if _, err := ParseContentType(resp.Header.Get("Content-Type")); err != nil {
+		return nil, err
+	}
+	// A stream may go on for as long as it likes, so only the
+	// values in it are limited.
+	limits := c.limits
+	limits.MaxBytes = 0
+	sr.dec = c.newDecoder(resp.Body, limits)
+	return sr, nil
+}
+
+// NewStreamReader calls DefaultCodec.NewStreamReader.
+func NewStreamReader(resp *http.Response) (*StreamReader, error) {
+	return DefaultCodec.NewStreamReader(resp)
+}
+
+// Next returns the next value, or io.EOF at the end of the stream.
+func (sr *StreamReader) Next() (interface{}, error) {
+	if sr.dec != nil {
+		return sr.dec.Decode()
+	}
+
+	data, err := sr.nextEvent()
+	if err != nil {
+		return nil, err
+	}
+	x, err := sr.codec.newDecoder(strings.NewReader(data), sr.codec.limits).Decode()
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return x, err
+}
+
+// nextEvent reads up to the end of the next event that has data and
+// returns the data. Comments, such as the ones servers send to keep
+// connections open, and unknown fields are skipped.
+func (sr *StreamReader) nextEvent() (string, error) {
+	var data []string
+	sr.event, sr.id = "", ""
+
+	for sr.events.Scan() {
+		line := strings.TrimSuffix(sr.events.Text(), "\r")
+		if line == "" {
+			if len(data) > 0 {
+				return strings.Join(data, "\n"), nil
+			}
+			continue
+		}
+
+		name, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch name {
+		case "data":
+			data = append(data, value)
+		case "event":
+			sr.event = value
+		case "id":
+			sr.id = value
+		}
+	}
+
+	if err := sr.events.Err(); err != nil {
+		return "", err
+	}
+	if len(data) > 0 {
+		return "", io.ErrUnexpectedEOF
+	}
+	return "", io.EOF
+}
+
+// Event returns the type of the event that the last value came in,
+// or "" if it had none or the stream is made of lines.
+func (sr *StreamReader) Event() string {
+	return sr.event
+}
+
+// ID returns the id of the event that the last value came in, or ""
+// if it had none or the stream is made of lines.
+func (sr *StreamReader) ID() string {
+	return sr.id
+}
+
+// All returns an iterator over the values in the stream. It stops
+// after the first error; io.EOF is not passed on.
+func (sr *StreamReader) All() iter.Seq2[interface{}, error] {
+	return func(yield func(interface{}, error) bool) {
+		for {
+			x, err := sr.Next()
+			if err == io.EOF {
+				return
+			}
+			if !yield(x, err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
+// Close closes the response body, ending the stream.
+func (sr *StreamReader) Close() error {
+	return sr.body.Close()
+}
+
+// Stream sends a GET request to url asking for a stream in the given
+// format and returns a StreamReader for the response. Close the
+// reader, or cancel ctx, to hang up.
+func (c *Client) Stream(ctx context.Context, url string, format StreamFormat) (*StreamReader, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", format.ContentType())
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		defer resp.Body.Close()
+		return nil, c.readError(resp)
+	}
+
+	sr, err := c.codec.NewStreamReader(resp)
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return sr, nil
+}
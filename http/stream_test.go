@@ -0,0 +1,206 @@
+// Copyright 2016 Russ Olsen. All Rights Reserved.
+//
+// This code is a Go port of the Java version created and maintained by Cognitect, therefore:
+//
+// Copyright 2014 Cognitect. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS-IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"context"
+	"github.com/russolsen/transit"
+	"io"
+	"iter"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+var feed = []interface{}{
+	transit.Keyword("start"),
+	map[interface{}]interface{}{transit.Keyword("price"): 1.5, transit.Keyword("tags"): transit.MakeSet("a")},
+	[]interface{}{int64(1), "two", nil},
+}
+
+func collect(t *testing.T, sr *StreamReader) []interface{} {
+	var values []interface{}
+	for x, err := range sr.All() {
+		if err != nil {
+			t.Fatal(err)
+		}
+		values = append(values, x)
+	}
+	return values
+}
+
+func TestStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Stream(w, r, slices.Values(feed))
+	}))
+	defer server.Close()
+	client := NewClient(server.Client())
+
+	for _, format := range []StreamFormat{StreamLines, StreamLinesVerbose, StreamEvents} {
+		sr, err := client.Stream(context.Background(), server.URL, format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if values := collect(t, sr); !reflect.DeepEqual(values, feed) {
+			t.Errorf("%v: expected %v, got %v", format.ContentType(), feed, values)
+		}
+		sr.Close()
+	}
+}
+
+// TestStreamLive checks that each value reaches the client as soon as
+// it is written.
+func TestStreamLive(t *testing.T) {
+	next := make(chan struct{})
+	ticks := func(yield func(int) bool) {
+		for i := 0; i < 3; i++ {
+			if !yield(i) {
+				return
+			}
+			<-next
+		}
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Stream(w, r, iter.Seq[int](ticks))
+	}))
+	defer server.Close()
+	client := NewClient(server.Client())
+
+	for _, format := range []StreamFormat{StreamLines, StreamEvents} {
+		sr, err := client.Stream(context.Background(), server.URL, format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 3; i++ {
+			x, err := sr.Next()
+			if err != nil || x != int64(i) {
+				t.Fatalf("%v: expected %d, got %v, %v", format.ContentType(), i, x, err)
+			}
+			next <- struct{}{}
+		}
+		if _, err := sr.Next(); err != io.EOF {
+			t.Errorf("Expected the end of the stream, got %v", err)
+		}
+		sr.Close()
+	}
+}
+
+func TestStreamWriter(t *testing.T) {
+	w := httptest.NewRecorder()
+	sw := NewStreamWriter(w, StreamEvents)
+	sw.WriteEvent("tick", "7", transit.Keyword("a"))
+	sw.Write(1)
+
+	expected := "event: tick\nid: 7\ndata: [\"~#'\",\"~:a\"]\n\ndata: [\"~#'\",1]\n\n"
+	if body := w.Body.String(); body != expected {
+		t.Errorf("Expected %q, got %q", expected, body)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != ContentTypeEventStream {
+		t.Errorf("Expected %s, got %s", ContentTypeEventStream, ct)
+	}
+	if !w.Flushed {
+		t.Errorf("Expected the events to be flushed")
+	}
+
+	for _, bad := range [][2]string{{"tick\ndata: forged", ""}, {"", "7\r"}} {
+		if err := sw.WriteEvent(bad[0], bad[1], 1); err == nil {
+			t.Errorf("Expected an error for event %q and id %q", bad[0], bad[1])
+		}
+	}
+	if body := w.Body.String(); body != expected {
+		t.Errorf("Expected nothing more to be written, got %q", body)
+	}
+
+	w = httptest.NewRecorder()
+	sw = NewStreamWriter(w, StreamLines)
+	if err := sw.Write(make(chan int)); err == nil {
+		t.Errorf("Expected an error for a value that cannot be encoded")
+	}
+	sw.WriteEvent("ignored", "1", []int{1})
+	if body := w.Body.String(); body != "[1]\n" {
+		t.Errorf("Expected a single line, got %q", body)
+	}
+}
+
+func TestStreamReaderEvents(t *testing.T) {
+	body := ": keep-alive\r\n\r\n" +
+		"retry: 1000\n" +
+		"event: quote\n" +
+		"id: 1\n" +
+		"data: [\"^ \",\n" +
+		"data:\"~:price\",1.5]\n" +
+		"\n" +
+		"data: \"~:next\"\n" +
+		"\n" +
+		"data: [1,"
+
+	resp := &http.Response{
+		Header: http.Header{"Content-Type": {"text/event-stream; charset=utf-8"}},
+		Body:   io.NopCloser(strings.NewReader(body)),
+	}
+	sr, err := NewStreamReader(resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	x, err := sr.Next()
+	expected := map[interface{}]interface{}{transit.Keyword("price"): 1.5}
+	if err != nil || !reflect.DeepEqual(x, expected) || sr.Event() != "quote" || sr.ID() != "1" {
+		t.Errorf("Expected quote 1 %v, got %s %s %v, %v", expected, sr.Event(), sr.ID(), x, err)
+	}
+
+	x, err = sr.Next()
+	if err != nil || x != transit.Keyword("next") || sr.Event() != "" || sr.ID() != "" {
+		t.Errorf("Expected :next, got %s %s %v, %v", sr.Event(), sr.ID(), x, err)
+	}
+
+	if _, err = sr.Next(); err != io.ErrUnexpectedEOF {
+		t.Errorf("Expected an unfinished event, got %v", err)
+	}
+}
+
+func TestStreamErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Stream(w, r, slices.Values(feed))
+	}))
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	req.Header.Set("Accept", ContentTypeMsgpack)
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotAcceptable {
+		t.Errorf("Expected 406, got %d", resp.StatusCode)
+	}
+
+	resp = &http.Response{
+		Header: http.Header{"Content-Type": {"text/plain"}},
+		Body:   io.NopCloser(strings.NewReader("")),
+	}
+	if _, err := NewStreamReader(resp); status(err) != http.StatusUnsupportedMediaType {
+		t.Errorf("Expected text/plain to be refused, got %v", err)
+	}
+}
@@ -0,0 +1,253 @@
+// Copyright 2016 Russ Olsen. All Rights Reserved.
+//
+// This code is a Go port of the Java version created and maintained by Cognitect, therefore:
+//
+// Copyright 2014 Cognitect. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS-IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+// The transit types implement json.Marshaler and json.Unmarshaler, so
+// that they can appear in ordinary JSON documents. The mapping is
+//
+//	Keyword, Symbol  string holding the name, without a colon
+//	TUri             string
+//	Set              array of the elements
+//	CMap             array of [key, value] pairs
+//	Link             object with href, rel, name, prompt and render
+//	TaggedValue      object with the tag and the value, {"tag": ..., "value": ...}
+//
+// Values inside sets, maps and tagged values are mapped the same way.
+// Maps whose keys are all strings, keywords or symbols become
+// objects, other maps become arrays of pairs, as CMaps do, and
+// *url.URLs become strings. Since JSON knows less than transit, values
+// read back from JSON are plain: numbers are int64 or float64, objects
+// map[string]interface{} and so on. Use Embedded to keep a value's
+// transit types all the way through.
+
+func (k Keyword) MarshalJSON() ([]byte, error) {
+	return json.Marshal(string(k))
+}
+
+func (k *Keyword) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := unmarshalJSONAs(b, &s, "string"); err != nil {
+		return err
+	}
+	*k = Keyword(s)
+	return nil
+}
+
+func (s Symbol) MarshalJSON() ([]byte, error) {
+	return json.Marshal(string(s))
+}
+
+func (s *Symbol) UnmarshalJSON(b []byte) error {
+	var name string
+	if err := unmarshalJSONAs(b, &name, "string"); err != nil {
+		return err
+	}
+	*s = Symbol(name)
+	return nil
+}
+
+func (turi TUri) MarshalJSON() ([]byte, error) {
+	return json.Marshal(turi.Value)
+}
+
+func (turi *TUri) UnmarshalJSON(b []byte) error {
+	return unmarshalJSONAs(b, &turi.Value, "string")
+}
+
+func (s Set) MarshalJSON() ([]byte, error) {
+	return json.Marshal(plainJSON(s.Contents))
+}
+
+func (s *Set) UnmarshalJSON(b []byte) error {
+	var contents []interface{}
+	if err := unmarshalJSONAs(b, &contents, "array"); err != nil {
+		return err
+	}
+	s.Contents = fromJSON(contents).([]interface{})
+	return nil
+}
+
+func (cm CMap) MarshalJSON() ([]byte, error) {
+	pairs := make([]interface{}, len(cm.Entries))
+	for i, entry := range cm.Entries {
+		pairs[i] = []interface{}{plainJSON(entry.Key), plainJSON(entry.Value)}
+	}
+	return json.Marshal(pairs)
+}
+
+func (cm *CMap) UnmarshalJSON(b []byte) error {
+	var pairs [][]interface{}
+	if err := unmarshalJSONAs(b, &pairs, "array of pairs"); err != nil {
+		return err
+	}
+
+	cm.Entries = make([]CMapEntry, len(pairs))
+	for i, pair := range pairs {
+		if len(pair) != 2 {
+			return &TransitError{Kind: KindType, Message: fmt.Sprintf("Expected a pair, got %d elements", len(pair)), Source: pair, Path: []interface{}{i}}
+		}
+		cm.Entries[i] = CMapEntry{Key: fromJSON(pair[0]), Value: fromJSON(pair[1])}
+	}
+	return nil
+}
+
+// jsonLink gives the fields of a Link their JSON names.
+type jsonLink struct {
+	Href   *TUri  `json:"href"`
+	Rel    string `json:"rel"`
+	Name   string `json:"name,omitempty"`
+	Prompt string `json:"prompt,omitempty"`
+	Render string `json:"render"`
+}
+
+func (l Link) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonLink(l))
+}
+
+func (l *Link) UnmarshalJSON(b []byte) error {
+	var jl jsonLink
+	if err := unmarshalJSONAs(b, &jl, "object"); err != nil {
+		return err
+	}
+	*l = Link(jl)
+	return nil
+}
+
+// jsonTaggedValue is the JSON form of a TaggedValue.
+type jsonTaggedValue struct {
+	Tag   string      `json:"tag"`
+	Value interface{} `json:"value"`
+}
+
+func (tv TaggedValue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonTaggedValue{Tag: string(tv.Tag), Value: plainJSON(tv.Value)})
+}
+
+func (tv *TaggedValue) UnmarshalJSON(b []byte) error {
+	var jtv jsonTaggedValue
+	if err := unmarshalJSONAs(b, &jtv, "object"); err != nil {
+		return err
+	}
+	*tv = TaggedValue{Tag: TagId(jtv.Tag), Value: fromJSON(jtv.Value)}
+	return nil
+}
+
+// unmarshalJSONAs decodes b into v, keeping numbers as json.Numbers
+// for fromJSON. A mismatch is an error of kind KindType.
+func unmarshalJSONAs(b []byte, v interface{}, what string) error {
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	if err := d.Decode(v); err != nil {
+		return &TransitError{Kind: KindType, Message: "Expected a JSON " + what, Source: string(b), Err: err}
+	}
+	return nil
+}
+
+// plainJSON returns x in a form encoding/json can write: decoded maps
+// become objects or arrays of pairs and URLs strings. Transit types
+// map themselves.
+func plainJSON(x interface{}) interface{} {
+	switch v := x.(type) {
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i := range v {
+			result[i] = plainJSON(v[i])
+		}
+		return result
+	case map[interface{}]interface{}:
+		return plainJSONMap(v)
+	case *url.URL:
+		return v.String()
+	}
+	return x
+}
+
+func plainJSONMap(m map[interface{}]interface{}) interface{} {
+	object := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		var name string
+		switch k := k.(type) {
+		case string:
+			name = k
+		case Keyword:
+			name = string(k)
+		case Symbol:
+			name = string(k)
+		default:
+			return CMap{Entries: cmapEntries(m)}
+		}
+		object[name] = plainJSON(v)
+	}
+
+	if len(object) != len(m) {
+		// Say "a" and :a, which would collide.
+		return CMap{Entries: cmapEntries(m)}
+	}
+	return object
+}
+
+func cmapEntries(m map[interface{}]interface{}) []CMapEntry {
+	entries := make([]CMapEntry, 0, len(m))
+	for k, v := range m {
+		entries = append(entries, CMapEntry{Key: k, Value: v})
+	}
+	return entries
+}
+
+// Embedded holds a value that is written to JSON as a nested
+// transit+json document, so that it keeps its transit types on the
+// way through an ordinary JSON API. Structs go through the struct
+// mapping and are read back with DecodeInto, as with Column. A nil V
+// is written as null, and null reads as the zero value of T.
+type Embedded[T any] struct {
+	V T
+}
+
+func (e Embedded[T]) MarshalJSON() ([]byte, error) {
+	b, err := encodeMapped(e.V)
+	if err != nil {
+		return nil, err
+	}
+	if b == nil {
+		return []byte("null"), nil
+	}
+	return b, nil
+}
+
+func (e *Embedded[T]) UnmarshalJSON(b []byte) error {
+	var v T
+	if !bytes.Equal(bytes.TrimSpace(b), []byte("null")) {
+		x, err := DecodeBytes(b)
+		if err != nil {
+			return err
+		}
+		if err := assignTo(&v, x); err != nil {
+			return err
+		}
+	}
+	e.V = v
+	return nil
+}
@@ -0,0 +1,184 @@
+// Copyright 2016 Russ Olsen. All Rights Reserved.
+//
+// This code is a Go port of the Java version created and maintained by Cognitect, therefore:
+//
+// Copyright 2014 Cognitect. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS-IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transit
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/pborman/uuid"
+	"reflect"
+	"testing"
+)
+
+func TestMarshalJSON(t *testing.T) {
+	tests := []struct {
+		value    interface{}
+		expected string
+	}{
+		{Keyword("status"), `"status"`},
+		{Symbol("inc"), `"inc"`},
+		{NewTUri("http://example.com/é"), `"http://example.com/é"`},
+		{MakeSet(Keyword("a"), 1), `["a",1]`},
+		{MakeSet(), `[]`},
+		{NewCMap().Append([]interface{}{1}, Keyword("x")), `[[[1],"x"]]`},
+		{Link{Href: NewTUri("/next"), Rel: "next", Render: "link"}, `{"href":"/next","rel":"next","render":"link"}`},
+		{TaggedValue{TagId("point"), []interface{}{1, 2}}, `{"tag":"point","value":[1,2]}`},
+		{MakeSet(map[interface{}]interface{}{Keyword("a"): toUrl("http://example.com")}), `[{"a":"http://example.com"}]`},
+		{MakeSet(map[interface{}]interface{}{int64(1): "one"}), `[[[1,"one"]]]`},
+		{map[Keyword]*Set{"tags": MakeSet(Symbol("x"))}, `{"tags":["x"]}`},
+	}
+
+	for _, test := range tests {
+		b, err := json.Marshal(test.value)
+		if err != nil {
+			t.Errorf("Error marshaling %v: %v", test.value, err)
+			continue
+		}
+		if string(b) != test.expected {
+			t.Errorf("Expected %s, got %s", test.expected, b)
+		}
+	}
+}
+
+func TestUnmarshalJSON(t *testing.T) {
+	var k Keyword
+	var s Symbol
+	var u TUri
+	var set Set
+	var cm CMap
+	var l Link
+	var tv TaggedValue
+
+	tests := []struct {
+		input    string
+		v        interface{}
+		expected interface{}
+	}{
+		{`"status"`, &k, Keyword("status")},
+		{`"inc"`, &s, Symbol("inc")},
+		{`"/x"`, &u, TUri{"/x"}},
+		{`["a",1,2.5,{"b":null}]`, &set, Set{[]interface{}{"a", int64(1), 2.5, map[string]interface{}{"b": nil}}}},
+		{`[[[1],"x"]]`, &cm, CMap{[]CMapEntry{{[]interface{}{int64(1)}, "x"}}}},
+		{`{"href":"/next","rel":"next","render":"link"}`, &l, Link{Href: NewTUri("/next"), Rel: "next", Render: "link"}},
+		{`{"tag":"point","value":[1,2]}`, &tv, TaggedValue{TagId("point"), []interface{}{int64(1), int64(2)}}},
+	}
+
+	for _, test := range tests {
+		if err := json.Unmarshal([]byte(test.input), test.v); err != nil {
+			t.Errorf("Error unmarshaling %s: %v", test.input, err)
+			continue
+		}
+		if actual := reflect.ValueOf(test.v).Elem().Interface(); !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("Expected %v, got %v", test.expected, actual)
+		}
+	}
+
+	for _, input := range []string{`1`, `{}`} {
+		err := json.Unmarshal([]byte(input), &k)
+		VerifyError(t, err, KindType, "[]")
+	}
+	VerifyError(t, json.Unmarshal([]byte(`[[1,2,3]]`), &cm), KindType, "[0]")
+}
+
+type apiOrder struct {
+	Status  Keyword                 `json:"status"`
+	Tags    *Set                    `json:"tags"`
+	Payload Embedded[*Set]          `json:"payload"`
+	Meta    Embedded[interface{}]   `json:"meta"`
+	Owner   Embedded[testAddress]   `json:"owner"`
+	IDs     Embedded[[]uuid.UUID]   `json:"ids"`
+	Empty   Embedded[map[int]int64] `json:"empty"`
+}
+
+func TestEmbeddedJSON(t *testing.T) {
+	id := uuid.Parse("b51241e0-c115-11e5-a837-0800200c9a66")
+	order := apiOrder{
+		Status:  "open",
+		Tags:    MakeSet(Keyword("rush")),
+		Payload: Embedded[*Set]{MakeSet(Keyword("rush"))},
+		Meta:    Embedded[interface{}]{Keyword("x")},
+		Owner:   Embedded[testAddress]{testAddress{City: "Paris"}},
+		IDs:     Embedded[[]uuid.UUID]{[]uuid.UUID{id}},
+	}
+
+	b, err := json.Marshal(order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"status":"open","tags":["rush"],"payload":["~#set",["~:rush"]],"meta":["~#'","~:x"],` +
+		`"owner":["^ ","~:city","Paris"],"ids":["~ub51241e0-c115-11e5-a837-0800200c9a66"],"empty":null}`
+	if string(b) != expected {
+		t.Errorf("Expected %s, got %s", expected, b)
+	}
+
+	var decoded apiOrder
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	// Only the embedded fields keep their keywords.
+	order.Tags = MakeSet("rush")
+	if !reflect.DeepEqual(decoded, order) {
+		t.Errorf("Expected %+v, got %+v", order, decoded)
+	}
+
+	err = json.Unmarshal([]byte(`{"owner":["^ ","~:city",1]}`), &decoded)
+	VerifyError(t, err, KindType, "[:city]")
+}
+
+// The package's own types have MarshalJSON methods, which must not
+// take over from their transit handlers when the fallbacks are on.
+func TestJSONMarshalersWithFallbacks(t *testing.T) {
+	cmap := NewCMap().Append([]interface{}{int64(1)}, Keyword("x"))
+	uri := NewTUri("http://example.com/")
+	values := []interface{}{
+		Keyword("k"), Symbol("s"), *uri, uri, *MakeSet(Keyword("a")), *cmap, cmap,
+		TaggedValue{TagId("point"), []interface{}{1, 2}},
+	}
+
+	plain, err := EncodeToString(values, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	e := NewEncoder(&buf, false)
+	e.SetFallbacks(DefaultFallbacks)
+	if err := e.Encode(values); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != plain {
+		t.Errorf("Expected %v with the fallbacks on, got %v", plain, buf.String())
+	}
+
+	decoded, err := DecodeFromString(buf.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	array := decoded.([]interface{})
+	for _, i := range []int{2, 3} {
+		if !reflect.DeepEqual(array[i], uri) {
+			t.Errorf("Expected %v at %v, got %#v", uri, i, array[i])
+		}
+	}
+	for _, i := range []int{5, 6} {
+		if !reflect.DeepEqual(array[i], cmap) {
+			t.Errorf("Expected %v at %v, got %#v", cmap, i, array[i])
+		}
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright 2016 Russ Olsen. All Rights Reserved.
+//
+// This code is a Go port of the Java version created and maintained by Cognitect, therefore:
+//
+// Copyright 2014 Cognitect. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS-IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transit
+
+import (
+	"fmt"
+)
+
+// Limits bounds the resources a Decoder will spend on its input. A zero
+// value for any field means that there is no limit, except that nesting
+// always stops at a depth of 10000. Use Limits to guard services that decode
+// transit from untrusted sources.
+type Limits struct {
+	MaxDepth        int   // Maximum nesting of arrays and maps.
+	MaxElements     int   // Maximum number of elements in a single array or map.
+	MaxStringLength int   // Maximum length in bytes of any string.
+	MaxDigits       int   // Maximum digits in a number, big integer or big decimal.
+	MaxBytes        int64 // Maximum total number of bytes read from the stream.
+}
+
+// DefaultLimits are reasonable limits for decoding transit from
+// untrusted sources.
+var DefaultLimits = Limits{
+	MaxDepth:        1000,
+	MaxElements:     1 << 20,
+	MaxStringLength: 1 << 24,
+	MaxDigits:       1000,
+	MaxBytes:        1 << 26,
+}
+
+// maxNesting is the deepest a Decoder will nest arrays and maps when
+// MaxDepth is zero. Decoding recurses, so without it deeply nested
+// input would overflow the stack.
+const maxNesting = 10000
+
+func newLimitError(what string, limit int64, source interface{}) *TransitError {
+	msg := fmt.Sprintf("%s exceeds limit of %d", what, limit)
+	return &TransitError{Kind: KindLimit, Message: msg, Source: source}
+}
+
+// limiter keeps track of how close the Decoder is to its Limits.
+type limiter struct {
+	Limits
+	depth int
+}
+
+func (l *limiter) enter() error {
+	l.depth++
+	max := l.MaxDepth
+	if max <= 0 {
+		max = maxNesting
+	}
+	if l.depth > max {
+		return newLimitError("Nesting depth", int64(max), nil)
+	}
+	return nil
+}
+
+func (l *limiter) leave() {
+	l.depth--
+}
+
+func (l *limiter) checkElements(n int) error {
+	if l.MaxElements > 0 && n > l.MaxElements {
+		return newLimitError("Number of elements", int64(l.MaxElements), n)
+	}
+	return nil
+}
+
+func (l *limiter) checkLength(n int) error {
+	if l.MaxStringLength > 0 && n > l.MaxStringLength {
+		return newLimitError("String length", int64(l.MaxStringLength), n)
+	}
+	return nil
+}
+
+func (l *limiter) checkDigits(n int) error {
+	if l.MaxDigits > 0 && n > l.MaxDigits {
+		return newLimitError("Number of digits", int64(l.MaxDigits), n)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+// Copyright 2016 Russ Olsen. All Rights Reserved.
+//
+// This code is a Go port of the Java version created and maintained by Cognitect, therefore:
+//
+// Copyright 2014 Cognitect. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS-IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transit
+
+import (
+	"sync"
+)
+
+// Encoders for Marshal are kept in pools, along with the type plans
+// they have compiled. Pooled encoders have no writer: their output
+// accumulates in the slice being appended to.
+var encoderPools = [2]sync.Pool{
+	{New: func() interface{} { return NewEncoder(nil, false) }},
+	{New: func() interface{} { return NewEncoder(nil, true) }},
+}
+
+// Marshal returns the non-verbose transit encoding of x.
+func Marshal(x interface{}) ([]byte, error) {
+	return marshalAppend(nil, x, false)
+}
+
+// MarshalAppend appends the non-verbose transit encoding of x to dst
+// and returns the extended slice. If there is an error, dst is
+// returned unchanged.
+func MarshalAppend(dst []byte, x interface{}) ([]byte, error) {
+	return marshalAppend(dst, x, false)
+}
+
+func marshalAppend(dst []byte, x interface{}, verbose bool) ([]byte, error) {
+	pool := &encoderPools[0]
+	if verbose {
+		pool = &encoderPools[1]
+	}
+
+	e := pool.Get().(*Encoder)
+	e.emitter.out.appendTo(dst)
+
+	err := e.Encode(x)
+	result := e.emitter.out.buf
+
+	e.emitter.out.appendTo(nil)
+	pool.Put(e)
+
+	if err != nil {
+		return dst, err
+	}
+	return result, nil
+}
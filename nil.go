@@ -0,0 +1,59 @@
+// Copyright 2016 Russ Olsen. All Rights Reserved.
+//
+// This code is a Go port of the Java version created and maintained by Cognitect, therefore:
+//
+// Copyright 2014 Cognitect. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS-IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transit
+
+import (
+	"reflect"
+)
+
+// Nil values of every type encode as transit null: nil pointers, maps,
+// slices and interfaces alike. Handlers never see them. The one
+// exception is that an Encoder can be told to write nil maps and
+// slices as empty collections instead, see SetNilAsEmpty.
+
+// nilable reports whether values of type t can be nil.
+func nilable(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface:
+		return true
+	}
+	return false
+}
+
+// encodesAsNil reports whether v should be written as null.
+func (e Encoder) encodesAsNil(v reflect.Value) bool {
+	if !nilable(v.Type()) || !v.IsNil() {
+		return false
+	}
+	if e.nilAsEmpty {
+		kind := v.Kind()
+		return kind != reflect.Map && kind != reflect.Slice
+	}
+	return true
+}
+
+// orNil wraps encode so that nil values are written as null.
+func orNil(encode encodeFunc) encodeFunc {
+	return func(e Encoder, v reflect.Value, asKey bool) error {
+		if e.encodesAsNil(v) {
+			return e.emitter.EmitNil(asKey)
+		}
+		return encode(e, v, asKey)
+	}
+}
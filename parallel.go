@@ -0,0 +1,201 @@
+// Copyright 2016 Russ Olsen. All Rights Reserved.
+//
+// This code is a Go port of the Java version created and maintained by Cognitect, therefore:
+//
+// Copyright 2014 Cognitect. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS-IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transit
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"iter"
+	"runtime"
+)
+
+// DefaultMaxChunkSize is the largest chunk a ParallelDecoder accepts
+// unless told otherwise.
+const DefaultMaxChunkSize = 64 << 20
+
+// ParallelDecoder decodes a stream of independent values on several
+// goroutines. Since the cache starts afresh with every top level
+// value, values can be decoded in any order once the stream has been
+// split at the boundaries between them. By default the stream is
+// split into lines, which suits newline delimited transit. The values
+// are delivered in the order they appear in the stream.
+type ParallelDecoder struct {
+	r            io.Reader
+	workers      int
+	split        bufio.SplitFunc
+	setup        func(*Decoder)
+	maxChunkSize int
+
+	continueOnError bool
+}
+
+// NewParallelDecoder returns a ParallelDecoder that reads from r and
+// decodes on the given number of goroutines. With workers zero or
+// less it uses one per CPU.
+func NewParallelDecoder(r io.Reader, workers int) *ParallelDecoder {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	return &ParallelDecoder{
+		r:            r,
+		workers:      workers,
+		split:        bufio.ScanLines,
+		maxChunkSize: DefaultMaxChunkSize,
+	}
+}
+
+// SetSplit sets the function that splits the stream into chunks,
+// each of which holds one value. Chunks that are all white space are
+// skipped.
+func (pd *ParallelDecoder) SetSplit(split bufio.SplitFunc) {
+	pd.split = split
+}
+
+// SetMaxChunkSize sets the size of the largest chunk the decoder
+// accepts. A bigger chunk ends decoding with bufio.ErrTooLong.
+func (pd *ParallelDecoder) SetMaxChunkSize(max int) {
+	pd.maxChunkSize = max
+}
+
+// Configure sets a function that is called with each worker's Decoder
+// before it is used, to add handlers or set limits.
+func (pd *ParallelDecoder) Configure(setup func(*Decoder)) {
+	pd.setup = setup
+}
+
+// SetContinueOnError controls whether All carries on after a chunk
+// fails to decode. Failing to read the stream always ends it.
+func (pd *ParallelDecoder) SetContinueOnError(continueOnError bool) {
+	pd.continueOnError = continueOnError
+}
+
+// chunk is one value's worth of input on its way through the workers.
+type chunk struct {
+	data   []byte
+	offset int64
+	result chan Result
+}
+
+// All returns an iterator over the values in the stream, in order.
+// Errors are *TransitErrors whose offset is counted from the start of
+// the stream, unless the stream itself could not be read. The workers
+// stop when the iteration ends.
+func (pd *ParallelDecoder) All() iter.Seq2[interface{}, error] {
+	return func(yield func(interface{}, error) bool) {
+		done := make(chan struct{})
+		defer close(done)
+
+		// Chunks go to the workers in any order, and into the queue in
+		// the order that their results are delivered. The size of the
+		// queue limits how far ahead of the consumer the workers get.
+		queue := make(chan *chunk, 2*pd.workers)
+		work := make(chan *chunk)
+
+		go pd.readChunks(queue, work, done)
+		for i := 0; i < pd.workers; i++ {
+			go pd.decodeChunks(work)
+		}
+
+		for c := range queue {
+			r := <-c.result
+			if !yield(r.Value, r.Err) {
+				return
+			}
+			// A chunk without data carries the error that ended the
+			// stream, so there is nothing to continue with.
+			if r.Err != nil && (!pd.continueOnError || c.data == nil) {
+				return
+			}
+		}
+	}
+}
+
+// readChunks splits the stream into chunks and hands them out.
+func (pd *ParallelDecoder) readChunks(queue, work chan<- *chunk, done <-chan struct{}) {
+	defer close(queue)
+	defer close(work)
+
+	var offset, start int64
+	split := func(data []byte, atEOF bool) (int, []byte, error) {
+		advance, token, err := pd.split(data, atEOF)
+		start = offset
+		offset += int64(advance)
+		return advance, token, err
+	}
+
+	sc := bufio.NewScanner(pd.r)
+	sc.Split(split)
+	sc.Buffer(nil, pd.maxChunkSize)
+
+	for sc.Scan() {
+		if len(bytes.TrimSpace(sc.Bytes())) == 0 {
+			continue
+		}
+		c := &chunk{data: bytes.Clone(sc.Bytes()), offset: start, result: make(chan Result, 1)}
+		select {
+		case queue <- c:
+		case <-done:
+			return
+		}
+		select {
+		case work <- c:
+		case <-done:
+			return
+		}
+	}
+
+	if err := sc.Err(); err != nil {
+		c := &chunk{result: make(chan Result, 1)}
+		c.result <- Result{Err: err}
+		select {
+		case queue <- c:
+		case <-done:
+		}
+	}
+}
+
+// decodeChunks decodes chunks until there are no more.
+func (pd *ParallelDecoder) decodeChunks(work <-chan *chunk) {
+	d := newBytesDecoder(nil)
+	if pd.setup != nil {
+		pd.setup(d)
+	}
+
+	for c := range work {
+		d.scan.resetBytes(c.data)
+		value, err := d.decodeChunk()
+		if te, ok := err.(*TransitError); ok {
+			err = te.at(te.Offset + c.offset)
+		}
+		c.result <- Result{value, err}
+	}
+}
+
+// decodeChunk decodes the one value that should make up the input.
+func (d Decoder) decodeChunk() (interface{}, error) {
+	value, err := d.Decode()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := d.scan.peek(); err != io.EOF {
+		return nil, d.scan.syntaxError("Unexpected data after value", err)
+	}
+	return value, nil
+}
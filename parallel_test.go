@@ -0,0 +1,137 @@
+// Copyright 2016 Russ Olsen. All Rights Reserved.
+//
+// This code is a Go port of the Java version created and maintained by Cognitect, therefore:
+//
+// Copyright 2014 Cognitect. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS-IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transit
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func ndjson(t testing.TB, values []interface{}) []byte {
+	var buf bytes.Buffer
+	for _, value := range values {
+		b, err := Marshal(value)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		buf.Write(b)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+func collectParallel(pd *ParallelDecoder) ([]interface{}, []error) {
+	var values []interface{}
+	var errs []error
+	for value, err := range pd.All() {
+		if err != nil {
+			errs = append(errs, err)
+		} else {
+			values = append(values, value)
+		}
+	}
+	return values, errs
+}
+
+func TestParallelDecoder(t *testing.T) {
+	var expected []interface{}
+	for i := 0; i < 1000; i++ {
+		expected = append(expected, []interface{}{Keyword("abcd"), int64(i), Keyword("abcd")})
+	}
+	input := ndjson(t, expected)
+
+	for _, workers := range []int{0, 1, 4} {
+		values, errs := collectParallel(NewParallelDecoder(bytes.NewReader(input), workers))
+		if len(errs) > 0 || !reflect.DeepEqual(values, expected) {
+			t.Errorf("Expected the values in order with %v workers, got %v values and %v", workers, len(values), errs)
+		}
+	}
+}
+
+func TestParallelDecoderErrors(t *testing.T) {
+	input := "1\n\n[2,,\n\"~xbad\"\n[3] 4\n5\n"
+
+	pd := NewParallelDecoder(strings.NewReader(input), 2)
+	pd.Configure(func(d *Decoder) { d.SetDefaultReadHandler(RejectUnknown) })
+
+	values, errs := collectParallel(pd)
+	if !reflect.DeepEqual(values, []interface{}{int64(1)}) || len(errs) != 1 {
+		t.Errorf("Expected to stop at the first error, got %v %v", values, errs)
+	}
+	if te := errs[0].(*TransitError); te.Offset != 6 {
+		t.Errorf("Expected the error at offset 6 of the stream, got %v", te.Offset)
+	}
+
+	pd = NewParallelDecoder(strings.NewReader(input), 2)
+	pd.Configure(func(d *Decoder) { d.SetDefaultReadHandler(RejectUnknown) })
+	pd.SetContinueOnError(true)
+
+	values, errs = collectParallel(pd)
+	if !reflect.DeepEqual(values, []interface{}{int64(1), int64(5)}) {
+		t.Errorf("Expected the good values, got %v", values)
+	}
+	kinds := []ErrorKind{KindSyntax, KindUnknownType, KindSyntax}
+	for i, kind := range kinds {
+		if i >= len(errs) || !errors.Is(errs[i], kind) {
+			t.Errorf("Expected errors of kinds %v, got %v", kinds, errs)
+			break
+		}
+	}
+}
+
+func TestParallelDecoderSplit(t *testing.T) {
+	pd := NewParallelDecoder(strings.NewReader(`1 "~:abc" [2,3]`), 3)
+	pd.SetSplit(bufio.ScanWords)
+
+	values, errs := collectParallel(pd)
+	expected := []interface{}{int64(1), Keyword("abc"), []interface{}{int64(2), int64(3)}}
+	if len(errs) > 0 || !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected %v, got %v %v", expected, values, errs)
+	}
+}
+
+func TestParallelDecoderLimits(t *testing.T) {
+	pd := NewParallelDecoder(strings.NewReader("1\n\"a long line\"\n3\n"), 2)
+	pd.SetMaxChunkSize(8)
+	pd.SetContinueOnError(true)
+
+	values, errs := collectParallel(pd)
+	if len(values) != 1 || len(errs) != 1 || !errors.Is(errs[0], bufio.ErrTooLong) {
+		t.Errorf("Expected the stream to end at the long line, got %v %v", values, errs)
+	}
+}
+
+func TestParallelDecoderBreak(t *testing.T) {
+	input := ndjson(t, make([]interface{}, 10000))
+
+	n := 0
+	for range NewParallelDecoder(bytes.NewReader(input), 4).All() {
+		n++
+		if n == 10 {
+			break
+		}
+	}
+	if n != 10 {
+		t.Errorf("Expected to stop after 10 values, got %v", n)
+	}
+}
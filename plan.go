@@ -0,0 +1,355 @@
+// Copyright 2016 Russ Olsen. All Rights Reserved.
+//
+// This code is a Go port of the Java version created and maintained by Cognitect, therefore:
+//
+// Copyright 2014 Cognitect. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS-IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transit
+
+import (
+	"reflect"
+)
+
+// encodeFunc encodes a single value of a known type.
+type encodeFunc func(e Encoder, v reflect.Value, asKey bool) error
+
+// typePlan is the compiled recipe for encoding one reflect.Type. Plans
+// are built the first time a type is seen and then reused, so that
+// the handler lookup only happens once per type.
+type typePlan struct {
+	encoder ValueEncoder
+	encode  encodeFunc
+}
+
+// encoderPlans holds the compiled plans for an Encoder.
+type encoderPlans struct {
+	byType map[reflect.Type]*typePlan
+
+	// fast is true as long as none of the handlers used by the
+	// fast paths in EncodeInterface have been replaced.
+	fast bool
+}
+
+func newEncoderPlans() *encoderPlans {
+	return &encoderPlans{byType: make(map[reflect.Type]*typePlan), fast: true}
+}
+
+// fastTypes are the types that EncodeInterface handles without
+// looking up a plan.
+var fastTypes = map[reflect.Type]bool{
+	reflect.TypeOf(""):                            true,
+	reflect.TypeOf(false):                         true,
+	reflect.TypeOf(0):                             true,
+	reflect.TypeOf(int64(0)):                      true,
+	reflect.TypeOf(0.0):                           true,
+	reflect.TypeOf(Keyword("")):                   true,
+	reflect.TypeOf([]interface{}{}):               true,
+	reflect.TypeOf(map[interface{}]interface{}{}): true,
+	reflect.TypeOf(map[string]interface{}{}):      true,
+}
+
+// fastKinds are the kinds of the fastTypes.
+var fastKinds = map[reflect.Kind]bool{
+	reflect.String:  true,
+	reflect.Bool:    true,
+	reflect.Int:     true,
+	reflect.Int64:   true,
+	reflect.Float64: true,
+	reflect.Slice:   true,
+	reflect.Map:     true,
+}
+
+// handlerAdded throws away the plans that may depend on the handler
+// for t, which is either a reflect.Type or a reflect.Kind.
+func (p *encoderPlans) handlerAdded(t interface{}) {
+	p.clear()
+
+	switch t := t.(type) {
+	case reflect.Type:
+		if fastTypes[t] {
+			p.fast = false
+		}
+	case reflect.Kind:
+		if fastKinds[t] {
+			p.fast = false
+		}
+	}
+}
+
+// clear throws away all of the plans.
+func (p *encoderPlans) clear() {
+	clear(p.byType)
+}
+
+// planFor returns the plan for type t, compiling it if need be.
+func (e Encoder) planFor(t reflect.Type) *typePlan {
+	if plan := e.plans.byType[t]; plan != nil {
+		return plan
+	}
+
+	plan := &typePlan{encoder: e.lookupEncoder(t)}
+
+	// Store the plan before compiling it so that recursive types
+	// find it rather than compiling forever.
+	e.plans.byType[t] = plan
+	plan.encode = e.compile(t, plan.encoder)
+	if nests(t) {
+		plan.encode = guard(plan.encode)
+	}
+	if nilable(t) {
+		plan.encode = orNil(plan.encode)
+	}
+
+	return plan
+}
+
+// lookupEncoder finds the encoder for t, first by the specific type,
+// then through the fallbacks and then by kind.
+func (e Encoder) lookupEncoder(t reflect.Type) ValueEncoder {
+	if typeEncoder := e.valueEncoders[t]; typeEncoder != nil {
+		return typeEncoder
+	}
+
+	if fallback := e.fallbackEncoder(t); fallback != nil {
+		return fallback
+	}
+
+	if kindEncoder := e.valueEncoders[t.Kind()]; kindEncoder != nil {
+		return kindEncoder
+	}
+
+	return NewErrorEncoder()
+}
+
+// compile builds the encode function for t. Slices, arrays and
+// pointers of a concrete element type get a function that goes
+// straight to the plan for their elements. Everything else simply
+// calls the encoder.
+func (e Encoder) compile(t reflect.Type, encoder ValueEncoder) encodeFunc {
+	switch encoder.(type) {
+	case *ArrayEncoder:
+		if t.Elem().Kind() != reflect.Interface {
+			return compileArray(e.planFor(t.Elem()))
+		}
+	case *PointerEncoder:
+		if t.Elem().Kind() != reflect.Interface {
+			return compilePointer(e.planFor(t.Elem()))
+		}
+	}
+	return encoder.Encode
+}
+
+func compileArray(elemPlan *typePlan) encodeFunc {
+	return func(e Encoder, v reflect.Value, asKey bool) error {
+		if err := e.emitter.EmitStartArray(); err != nil {
+			return err
+		}
+
+		l := v.Len()
+		for i := 0; i < l; i++ {
+			if i > 0 {
+				if err := e.emitter.EmitArraySeparator(); err != nil {
+					return err
+				}
+			}
+			if err := elemPlan.encode(e, v.Index(i), asKey); err != nil {
+				return withPath(err, i)
+			}
+		}
+
+		return e.emitter.EmitEndArray()
+	}
+}
+
+func compilePointer(elemPlan *typePlan) encodeFunc {
+	return func(e Encoder, v reflect.Value, asKey bool) error {
+		return elemPlan.encode(e, v.Elem(), asKey)
+	}
+}
+
+// encodeElement encodes a value pulled out of a collection. Elements
+// of interface type are unwrapped so that they get encoded according
+// to their dynamic type.
+func (e Encoder) encodeElement(v reflect.Value, asKey bool) error {
+	if v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return e.emitter.EmitNil(asKey)
+		}
+		v = v.Elem()
+	}
+	return e.planFor(v.Type()).encode(e, v, asKey)
+}
+
+// isStringable reports whether x can be encoded as a string, which
+// decides whether it can be used as a key in an ordinary map.
+func (e Encoder) isStringable(x interface{}) bool {
+	if e.plans.fast {
+		switch x.(type) {
+		case nil, string, bool, int, int64, float64, Keyword:
+			return true
+		case []interface{}, map[interface{}]interface{}, map[string]interface{}:
+			return false
+		}
+	}
+
+	v := reflect.ValueOf(x)
+	return e.ValueEncoderFor(v).IsStringable(v)
+}
+
+// encodeGenericArray is the fast path for []interface{}.
+func (e Encoder) encodeGenericArray(a []interface{}, asKey bool) error {
+	if a == nil && !e.nilAsEmpty {
+		return e.emitter.EmitNil(asKey)
+	}
+
+	v, err := enterGeneric(e, a)
+	if err != nil {
+		return err
+	}
+	defer e.depth.leave(v)
+
+	if err := e.emitter.EmitStartArray(); err != nil {
+		return err
+	}
+
+	for i, element := range a {
+		if i > 0 {
+			if err := e.emitter.EmitArraySeparator(); err != nil {
+				return err
+			}
+		}
+		if err := e.EncodeInterface(element, asKey); err != nil {
+			return withPath(err, i)
+		}
+	}
+
+	return e.emitter.EmitEndArray()
+}
+
+// encodeGenericMap is the fast path for map[interface{}]interface{}.
+func (e Encoder) encodeGenericMap(m map[interface{}]interface{}, asKey bool) error {
+	if m == nil && !e.nilAsEmpty {
+		return e.emitter.EmitNil(asKey)
+	}
+
+	for key := range m {
+		if !e.isStringable(key) {
+			return e.EncodeValue(reflect.ValueOf(m), false)
+		}
+	}
+
+	v, err := enterGeneric(e, m)
+	if err != nil {
+		return err
+	}
+	defer e.depth.leave(v)
+
+	if err := e.emitStartMap(e.verbose); err != nil {
+		return err
+	}
+
+	i := 0
+	for key, value := range m {
+		if err := e.emitBeforeKey(e.verbose, i); err != nil {
+			return err
+		}
+		if err := e.EncodeInterface(key, true); err != nil {
+			return withPath(err, key)
+		}
+		if err := e.emitBeforeValue(e.verbose); err != nil {
+			return err
+		}
+		if err := e.EncodeInterface(value, false); err != nil {
+			return withPath(err, key)
+		}
+		i++
+	}
+
+	return e.emitEndMap(e.verbose)
+}
+
+// encodeStringMap is the fast path for map[string]interface{}.
+func (e Encoder) encodeStringMap(m map[string]interface{}, asKey bool) error {
+	if m == nil && !e.nilAsEmpty {
+		return e.emitter.EmitNil(asKey)
+	}
+
+	v, err := enterGeneric(e, m)
+	if err != nil {
+		return err
+	}
+	defer e.depth.leave(v)
+
+	if err := e.emitStartMap(e.verbose); err != nil {
+		return err
+	}
+
+	i := 0
+	for key, value := range m {
+		if err := e.emitBeforeKey(e.verbose, i); err != nil {
+			return err
+		}
+		if err := encodeString(e, key, true); err != nil {
+			return withPath(err, key)
+		}
+		if err := e.emitBeforeValue(e.verbose); err != nil {
+			return err
+		}
+		if err := e.EncodeInterface(value, false); err != nil {
+			return withPath(err, key)
+		}
+		i++
+	}
+
+	return e.emitEndMap(e.verbose)
+}
+
+// emitStartMap starts a map, either as a JSON object in verbose mode
+// or as a "^ " array otherwise.
+func (e Encoder) emitStartMap(verbose bool) error {
+	if verbose {
+		return e.emitter.EmitStartMap()
+	}
+	if err := e.emitter.EmitStartArray(); err != nil {
+		return err
+	}
+	return e.emitter.EmitString(mapAsArray, false)
+}
+
+// emitBeforeKey emits the separator that comes before the i'th key.
+func (e Encoder) emitBeforeKey(verbose bool, i int) error {
+	if verbose {
+		if i == 0 {
+			return nil
+		}
+		return e.emitter.EmitMapSeparator()
+	}
+	return e.emitter.EmitArraySeparator()
+}
+
+// emitBeforeValue emits the separator between a key and its value.
+func (e Encoder) emitBeforeValue(verbose bool) error {
+	if verbose {
+		return e.emitter.EmitKeySeparator()
+	}
+	return e.emitter.EmitArraySeparator()
+}
+
+func (e Encoder) emitEndMap(verbose bool) error {
+	if verbose {
+		return e.emitter.EmitEndMap()
+	}
+	return e.emitter.EmitEndArray()
+}
@@ -0,0 +1,291 @@
+// Copyright 2016 Russ Olsen. All Rights Reserved.
+//
+// This code is a Go port of the Java version created and maintained by Cognitect, therefore:
+//
+// Copyright 2014 Cognitect. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS-IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transit
+
+// ReadHandler turns the representation of a tagged value into the
+// value itself. For scalar tags, such as "~i42", rep is the string
+// after the tag. For composite tags, such as {"~#set": [1, 2]}, rep
+// is the decoded value that follows the tag.
+type ReadHandler interface {
+	FromRep(ctx *ReadContext, rep interface{}) (interface{}, error)
+}
+
+// LazyReadHandler is a ReadHandler that wants the representation of
+// composite values undecoded. If Lazy returns true, FromRep gets a
+// RawValue, which it can decode later, or never, with
+// ReadContext.Decode.
+type LazyReadHandler interface {
+	ReadHandler
+	Lazy() bool
+}
+
+// ReadHandlerFunc lets an ordinary function serve as a ReadHandler.
+type ReadHandlerFunc func(ctx *ReadContext, rep interface{}) (interface{}, error)
+
+func (f ReadHandlerFunc) FromRep(ctx *ReadContext, rep interface{}) (interface{}, error) {
+	return f(ctx, rep)
+}
+
+// FromRep lets old style Handler functions serve as ReadHandlers.
+// Composite values are passed to the function as a TaggedValue, just
+// as they always have been.
+func (h Handler) FromRep(ctx *ReadContext, rep interface{}) (interface{}, error) {
+	if ctx.tagged {
+		rep = TaggedValue{Tag: TagId(ctx.tag), Value: rep}
+	}
+	return h(ctx.decoder, rep)
+}
+
+// PreserveUnknown is the default read handler. It turns values with
+// unknown tags into TaggedValues, so that they survive a round trip.
+var PreserveUnknown ReadHandler = ReadHandlerFunc(preserveUnknown)
+
+// RejectUnknown is a default read handler that fails on any value
+// with an unknown tag.
+var RejectUnknown ReadHandler = ReadHandlerFunc(rejectUnknown)
+
+func preserveUnknown(ctx *ReadContext, rep interface{}) (interface{}, error) {
+	return TaggedValue{Tag: TagId(ctx.Tag()), Value: rep}, nil
+}
+
+func rejectUnknown(ctx *ReadContext, rep interface{}) (interface{}, error) {
+	return nil, &TransitError{Kind: KindUnknownType, Message: "Unknown tag " + ctx.Tag(), Source: rep}
+}
+
+// ReadContext tells a ReadHandler about the value it is decoding.
+// The context is only good for the duration of the call to FromRep.
+type ReadContext struct {
+	decoder Decoder
+	tag     string
+	tagged  bool
+	asKey   bool
+}
+
+// Tag returns the tag of the value, without the "~" or "~#".
+func (ctx *ReadContext) Tag() string {
+	return ctx.tag
+}
+
+// AsKey reports whether the value is a map key.
+func (ctx *ReadContext) AsKey() bool {
+	return ctx.asKey
+}
+
+// Path returns the array indexes and map keys leading from the top
+// level value to this one.
+func (ctx *ReadContext) Path() []interface{} {
+	return ctx.decoder.path.list()
+}
+
+// Limits returns the limits of the decoder.
+func (ctx *ReadContext) Limits() Limits {
+	return ctx.decoder.Limits()
+}
+
+// Decoder returns the decoder that is reading the value.
+func (ctx *ReadContext) Decoder() Decoder {
+	return ctx.decoder
+}
+
+// Decode decodes a RawValue with the handlers, readers and limits of
+// the decoder. It can be called after FromRep has returned.
+func (ctx *ReadContext) Decode(raw RawValue) (interface{}, error) {
+	return ctx.decoder.fork(raw).Decode()
+}
+
+// RawValue is the representation of a tagged value as transit JSON,
+// undecoded. It does not depend on the rest of the stream: any cache
+// codes in it are expanded when it is read.
+type RawValue []byte
+
+func (raw RawValue) String() string {
+	return string(raw)
+}
+
+// pathEntry is one level of the path to the value being decoded.
+type pathEntry struct {
+	index  int         // The element being read, for arrays.
+	key    interface{} // The key of the entry being read, for maps.
+	isMap  bool
+	inKey  bool // Reading a map key, which is not known yet.
+	hidden bool // The array or map around a tagged value.
+}
+
+// readPath keeps track of where the decoder is. Indexes and keys are
+// only turned into a []interface{} when someone asks for them.
+type readPath struct {
+	entries []pathEntry
+}
+
+func (p *readPath) push() {
+	p.entries = append(p.entries, pathEntry{})
+}
+
+func (p *readPath) pop() {
+	p.entries = p.entries[:len(p.entries)-1]
+}
+
+func (p *readPath) setIndex(i int) {
+	p.entries[len(p.entries)-1].index = i
+}
+
+func (p *readPath) readingKey() {
+	e := &p.entries[len(p.entries)-1]
+	e.isMap, e.inKey, e.key = true, true, nil
+}
+
+func (p *readPath) setKey(key interface{}) {
+	e := &p.entries[len(p.entries)-1]
+	e.inKey, e.key = false, key
+}
+
+func (p *readPath) hide() {
+	e := &p.entries[len(p.entries)-1]
+	e.hidden, e.inKey = true, false
+}
+
+// inKey reports whether the tagged value being read is a map key.
+func (p *readPath) inKey() bool {
+	n := len(p.entries)
+	return n > 1 && p.entries[n-2].inKey
+}
+
+func (p *readPath) list() []interface{} {
+	result := []interface{}{}
+	for _, e := range p.entries {
+		switch {
+		case e.hidden || e.inKey:
+		case e.isMap:
+			result = append(result, e.key)
+		default:
+			result = append(result, e.index)
+		}
+	}
+	return result
+}
+
+// captureValue reads the next value without decoding it, appending
+// its JSON to out. Cache codes are replaced by the strings they stand
+// for, and cacheable strings are entered in the cache, just as if the
+// value had been decoded.
+func (d Decoder) captureValue(out []byte, asKey bool) ([]byte, error) {
+	c, err := d.scan.next()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case c == '"':
+		raw, err := d.scan.readString()
+		if err != nil {
+			return nil, err
+		}
+		return d.captureString(out, raw, asKey), nil
+	case c == '[' || c == '{':
+		return d.captureCollection(out, c)
+	case c == 't':
+		return append(out, "true"...), d.scan.readLiteral("true")
+	case c == 'f':
+		return append(out, "false"...), d.scan.readLiteral("false")
+	case c == 'n':
+		return append(out, "null"...), d.scan.readLiteral("null")
+	case c == '-' || (c >= '0' && c <= '9'):
+		number, _, err := d.scan.readNumber()
+		return append(out, number...), err
+	}
+	return nil, d.scan.syntaxError("Unexpected character '"+string(c)+"'", nil)
+}
+
+func (d Decoder) captureString(out []byte, raw []byte, asKey bool) []byte {
+	if len(raw) > 1 && raw[0] == '^' {
+		if cached, present := d.cache.lookup(raw); present {
+			return appendQuoted(out, cached)
+		}
+	}
+
+	s := string(raw)
+	if d.cache.IsCacheable(s, asKey) {
+		d.cache.Write(s)
+	}
+	return appendQuoted(out, s)
+}
+
+// captureCollection captures an array or a JSON object, keeping
+// track of which strings are map keys.
+func (d Decoder) captureCollection(out []byte, open byte) ([]byte, error) {
+	if err := d.limits.enter(); err != nil {
+		return nil, err
+	}
+	defer d.limits.leave()
+
+	d.scan.pos++
+	out = append(out, open)
+
+	end := byte(']')
+	if open == '{' {
+		end = '}'
+	}
+
+	arrayMap := false
+
+	for n := 0; ; n++ {
+		if n > 0 {
+			more, err := d.readSeparator(end)
+			if err != nil {
+				return nil, err
+			}
+			if !more {
+				return append(out, end), nil
+			}
+			out = append(out, ',')
+
+			if err := d.limits.checkElements(n + 1); err != nil {
+				return nil, err
+			}
+		} else if c, err := d.scan.next(); err != nil {
+			return nil, err
+		} else if c == end {
+			d.scan.pos++
+			return append(out, end), nil
+		}
+
+		var err error
+
+		if open == '{' {
+			if out, err = d.captureValue(out, true); err != nil {
+				return nil, err
+			}
+			if err := d.scan.expect(':'); err != nil {
+				return nil, err
+			}
+			out = append(out, ':')
+			out, err = d.captureValue(out, false)
+		} else if n == 0 {
+			start := len(out)
+			out, err = d.captureValue(out, false)
+			arrayMap = err == nil && string(out[start:]) == `"^ "`
+		} else {
+			out, err = d.captureValue(out, arrayMap && n%2 == 1)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,195 @@
+// Copyright 2016 Russ Olsen. All Rights Reserved.
+//
+// This code is a Go port of the Java version created and maintained by Cognitect, therefore:
+//
+// Copyright 2014 Cognitect. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS-IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transit
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// recordingHandler remembers what it saw in its context.
+type recordingHandler struct {
+	seen *[]string
+}
+
+func (rh recordingHandler) FromRep(ctx *ReadContext, rep interface{}) (interface{}, error) {
+	*rh.seen = append(*rh.seen, fmt.Sprintf("%v %v %v %v", ctx.Tag(), ctx.Path(), ctx.AsKey(), rep))
+	return rep, nil
+}
+
+// lazyHandler keeps the raw representation, to be decoded later.
+type lazyHandler struct{}
+
+type lazyValue struct {
+	ctx *ReadContext
+	raw RawValue
+}
+
+func (lh lazyHandler) FromRep(ctx *ReadContext, rep interface{}) (interface{}, error) {
+	return lazyValue{ctx, rep.(RawValue)}, nil
+}
+
+func (lh lazyHandler) Lazy() bool {
+	return true
+}
+
+func TestReadHandlerContext(t *testing.T) {
+	var seen []string
+
+	d := NewDecoder(strings.NewReader(`
+		["^ ", "~:items", [0, ["~#point", [1, 2]]], "~xkey", ["~xvalue"]]
+		{"~:items": [{"~#point": [3, 4]}]}`))
+	d.AddReadHandler("point", recordingHandler{&seen})
+	d.AddReadHandler("x", recordingHandler{&seen})
+
+	for i := 0; i < 2; i++ {
+		if _, err := d.Decode(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	expected := []string{
+		"point [:items 1] false [1 2]",
+		"x [] true key",
+		"x [key 0] false value",
+		"point [:items 0] false [3 4]",
+	}
+	if !reflect.DeepEqual(seen, expected) {
+		t.Errorf("Expected %q, got %q", expected, seen)
+	}
+}
+
+func TestReadHandlerAdapter(t *testing.T) {
+	var seen []string
+
+	d := NewDecoder(strings.NewReader(`["~#point", [1, 2]]`))
+	d.AddReadHandler("point", recordingHandler{&seen})
+
+	h := d.DecoderFor(TagId("point"))
+	value, err := h(*d, TaggedValue{TagId("point"), "rep"})
+	if err != nil || value != "rep" {
+		t.Errorf("Expected the adapted handler to return its rep, got %v: %v", value, err)
+	}
+
+	d.AddHandler("point", func(d Decoder, x interface{}) (interface{}, error) {
+		return x.(TaggedValue).Value, nil
+	})
+
+	value, err = d.Decode()
+	if err != nil || !reflect.DeepEqual(value, []interface{}{int64(1), int64(2)}) {
+		t.Errorf("Expected the old style handler to get a TaggedValue, got %v: %v", value, err)
+	}
+}
+
+func TestLazyReadHandler(t *testing.T) {
+	d := NewDecoder(strings.NewReader(
+		`["~:abcd", ["~#lazy", ["^0",  "~~x", {"key1": "^0"}, ["^ ", "key2", 1.5]]], "^0", "^2", "^3"]`))
+	d.AddReadHandler("lazy", lazyHandler{})
+
+	value, err := d.Decode()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	array := value.([]interface{})
+	lazy := array[1].(lazyValue)
+
+	expected := `["~:abcd","~~x",{"key1":"~:abcd"},["^ ","key2",1.5]]`
+	if lazy.raw.String() != expected {
+		t.Errorf("Expected raw value %v, got %v", expected, lazy.raw)
+	}
+
+	// The cache has to keep up with what was captured.
+	rest := []interface{}{Keyword("abcd"), "key1", "key2"}
+	if !reflect.DeepEqual(array[2:], rest) {
+		t.Errorf("Expected %v after the raw value, got %v", rest, array[2:])
+	}
+
+	decoded, err := lazy.ctx.Decode(lazy.raw)
+	if err != nil {
+		t.Fatalf("Unexpected error decoding raw value: %v", err)
+	}
+
+	inner := decoded.([]interface{})
+	assertEquals(t, Keyword("abcd"), inner[0])
+	assertEquals(t, "~x", inner[1])
+	assertEquals(t, Keyword("abcd"), inner[2].(map[interface{}]interface{})["key1"])
+	assertEquals(t, 1.5, inner[3].(map[interface{}]interface{})["key2"])
+}
+
+func TestReadHandlerErrors(t *testing.T) {
+	var seen []string
+
+	d := NewJsonDecoder(json.NewDecoder(strings.NewReader("")))
+	d.AddReadHandler("lazy", lazyHandler{})
+	_, err := d.Parse([]interface{}{"~#lazy", json.Number("1")}, false)
+	VerifyError(t, err, KindHandler, "[]")
+
+	d = NewDecoder(strings.NewReader(`{"a": ["~#point", "xyz"]}`))
+	d.AddReadHandler("point", ReadHandlerFunc(func(ctx *ReadContext, rep interface{}) (interface{}, error) {
+		seen = append(seen, fmt.Sprint(ctx.Path()))
+		return nil, fmt.Errorf("bad point %v", rep)
+	}))
+
+	_, err = d.Decode()
+	VerifyError(t, err, KindHandler, "[a]")
+	assertEquals(t, "[a]", seen[0])
+}
+
+func TestDefaultReadHandler(t *testing.T) {
+	input := `["~jfoo", {"~#point": [1, 2]}]`
+
+	value := DecodeTransit(t, input).([]interface{})
+	assertEquals(t, TaggedValue{TagId("j"), "foo"}, value[0])
+	assertEquals(t, TagId("point"), value[1].(TaggedValue).Tag)
+
+	d := NewDecoder(strings.NewReader(input))
+	d.SetDefaultReadHandler(RejectUnknown)
+	_, err := d.Decode()
+	VerifyError(t, err, KindUnknownType, "[0]")
+
+	var seen []string
+
+	d = NewDecoder(strings.NewReader(input))
+	d.SetDefaultReadHandler(ReadHandlerFunc(func(ctx *ReadContext, rep interface{}) (interface{}, error) {
+		seen = append(seen, fmt.Sprintf("%v %v", ctx.Tag(), rep))
+		return nil, nil
+	}))
+
+	value2, err := d.Decode()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(value2, []interface{}{nil, nil}) {
+		t.Errorf("Expected the callback's results, got %v", value2)
+	}
+	if !reflect.DeepEqual(seen, []string{"j foo", "point [1 2]"}) {
+		t.Errorf("Expected the callback to see both tags, got %v", seen)
+	}
+
+	d.SetDefaultReadHandler(nil)
+	h := d.DecoderFor(TagId("point"))
+	tv, err := h(*d, TaggedValue{TagId("point"), 1})
+	if err != nil || tv != (TaggedValue{TagId("point"), 1}) {
+		t.Errorf("Expected DecoderFor to fall back to the default handler, got %v: %v", tv, err)
+	}
+}
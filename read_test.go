@@ -19,13 +19,22 @@
 package transit
 
 import (
+	"bytes"
 	"container/list"
 	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/pborman/uuid"
 	"github.com/shopspring/decimal"
+	"io"
 	"math"
 	"math/big"
+	"reflect"
+	"strings"
 	"testing"
+	"testing/iotest"
+	"time"
 )
 
 func DecodeTransit(t *testing.T, s string) interface{} {
@@ -141,6 +150,122 @@ func TestReadArray(t *testing.T) {
 	assertEquals(t, l[2], int64(3))
 }
 
+func TestReadEscapes(t *testing.T) {
+	assertEquals(t, "a\"b\\c/d\n\té😀", DecodeTransit(t, `"a\"b\\c\/d\n\t\u00e9\ud83d\ude00"`))
+	assertEquals(t, "\ufffdx", DecodeTransit(t, `"\ud83dx"`))
+	assertEquals(t, "\ufffd", DecodeTransit(t, "\"\xff\""))
+	assertEquals(t, "~:foo", DecodeTransit(t, `"~~:foo"`))
+}
+
+func TestReadMalformed(t *testing.T) {
+	VerifyReadError(t, `[1, 2,]`)
+	VerifyReadError(t, `[1 2]`)
+	VerifyReadError(t, `{"a" 1}`)
+	VerifyReadError(t, `{"a": 1,}`)
+	VerifyReadError(t, `{1: 2}`)
+	VerifyReadError(t, `[01]`)
+	VerifyReadError(t, `[1.]`)
+	VerifyReadError(t, `[-]`)
+	VerifyReadError(t, `tru`)
+	VerifyReadError(t, `"abc`)
+	VerifyReadError(t, "\"a\x01\"")
+	VerifyReadError(t, `"\x"`)
+	VerifyReadError(t, `["~#set", [1], [2]]`)
+	VerifyReadError(t, `{"~#set": [1], "a": 2}`)
+	VerifyReadError(t, `["^ ", ["a"], 1]`)
+	VerifyReadError(t, `[99999999999999999999]`)
+	VerifyReadError(t, `["~#ratio",[1,0]]`)
+	VerifyReadError(t, `["~#ratio",["~n1","~n0"]]`)
+
+	for _, input := range []string{`"~"`, `["~"]`, `{"~":1}`} {
+		VerifyReadError(t, input)
+		for _, d := range []*Decoder{NewDecoder(strings.NewReader(input)), NewJsonDecoder(json.NewDecoder(strings.NewReader(input)))} {
+			if _, err := d.Decode(); !errors.Is(err, KindSyntax) {
+				t.Errorf("Expected a syntax error decoding %v, got %v", input, err)
+			}
+		}
+	}
+}
+
+func TestReadScalarReps(t *testing.T) {
+	for _, tag := range []string{":", "$", "t", "m", "?", "n", "i", "c", "d", "f", "b", "r", "u", "z"} {
+		for _, rep := range []string{"5", "[1]", `["^ "]`} {
+			input := fmt.Sprintf(`["~#%s",%s]`, tag, rep)
+			if _, err := DecodeFromString(input); !errors.Is(err, KindSyntax) {
+				t.Errorf("Expected a syntax error decoding %v, got %v", input, err)
+			}
+		}
+	}
+
+	when := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`["~#t","2020-01-01T00:00:00Z"]`, when},
+		{`["~#:","abc"]`, Keyword("abc")},
+		{`"~'abc"`, "abc"},
+	}
+	for _, test := range tests {
+		value, err := DecodeFromString(test.input)
+		if err != nil || !reflect.DeepEqual(value, test.expected) {
+			t.Errorf("Expected %v to decode as %v, got %v %v", test.input, test.expected, value, err)
+		}
+	}
+}
+
+func TestReadStream(t *testing.T) {
+	input := `"~:abcd" ["~:abcd", "^0"]
+		["^ ", "~:efgh", "^0", "~:ijkl", "^1"]  {"~#list": []} 1.5 `
+
+	// Reading a byte at a time makes the decoder refill its buffer
+	// in the middle of every token.
+	d := NewDecoder(iotest.OneByteReader(strings.NewReader(input)))
+
+	expected := []interface{}{
+		Keyword("abcd"),
+		[]interface{}{Keyword("abcd"), Keyword("abcd")},
+		map[interface{}]interface{}{Keyword("efgh"): Keyword("efgh"), Keyword("ijkl"): Keyword("ijkl")},
+	}
+
+	for _, e := range expected {
+		value, err := d.Decode()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(e, value) {
+			t.Errorf("Expected %v, got %v", e, value)
+		}
+	}
+
+	if l, err := d.Decode(); err != nil || l.(*list.List).Len() != 0 {
+		t.Errorf("Expected an empty list, got %v: %v", l, err)
+	}
+
+	if f, err := d.Decode(); err != nil || f != 1.5 {
+		t.Errorf("Expected 1.5, got %v: %v", f, err)
+	}
+
+	if _, err := d.Decode(); err != io.EOF {
+		t.Errorf("Expected io.EOF at the end of the stream, got %v", err)
+	}
+}
+
+func TestDecodeBytes(t *testing.T) {
+	input := []byte(`["^ ", "~:abc", ["~^ ", "^0"], "b", {"~#set": ["~i1"]}]`)
+
+	value, err := DecodeBytes(input)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	m := value.(map[interface{}]interface{})
+	if a := m[Keyword("abc")]; !reflect.DeepEqual(a, []interface{}{"^ ", Keyword("abc")}) {
+		t.Errorf("Expected an array holding \"^ \" and :abc, got %v", a)
+	}
+	assertTrue(t, m["b"].(*Set).ContainsEq(int64(1)))
+}
+
 func TestReadBinary(t *testing.T) {
 	value := []byte("foobarbaz")
 
@@ -223,3 +348,204 @@ func TestLink(t *testing.T) {
 	assertEquals(t, l.Render, "link")
 	assertEquals(t, l.Prompt, "p")
 }
+
+func VerifyLimitError(t *testing.T, limits Limits, transit string) {
+	d := NewDecoder(strings.NewReader(transit))
+	d.SetLimits(limits)
+
+	_, err := d.Decode()
+
+	te, ok := err.(*TransitError)
+	if !ok || te.Kind != KindLimit {
+		t.Errorf("Expected a limit error decoding [%v], got: %v", transit, err)
+	}
+}
+
+func TestReadLimits(t *testing.T) {
+	VerifyLimitError(t, Limits{MaxDepth: 2}, `[[[1]]]`)
+	VerifyLimitError(t, Limits{MaxDepth: 2}, `{"a": {"b": {"c": 1}}}`)
+	VerifyLimitError(t, Limits{MaxElements: 3}, `[1, 2, 3, 4]`)
+	VerifyLimitError(t, Limits{MaxStringLength: 5}, `["abcdef"]`)
+	VerifyLimitError(t, Limits{MaxDigits: 5}, `"~n1234567"`)
+	VerifyLimitError(t, Limits{MaxDigits: 5}, `"~f1234.567"`)
+	VerifyLimitError(t, Limits{MaxDigits: 5}, `{"~#ratio": ["~n1", "~n1000000"]}`)
+	VerifyLimitError(t, Limits{MaxDigits: 5}, `[1234567]`)
+	VerifyLimitError(t, Limits{MaxBytes: 10}, `["abc", "def", "ghi"]`)
+
+	d := NewDecoder(strings.NewReader(`[[1, 2], "abc", "~n12345"]`))
+	d.SetLimits(Limits{MaxDepth: 2, MaxElements: 3, MaxStringLength: 8, MaxDigits: 5, MaxBytes: 100})
+
+	if _, err := d.Decode(); err != nil {
+		t.Errorf("Unexpected error decoding within limits: %v", err)
+	}
+}
+
+func TestReadDeepNesting(t *testing.T) {
+	if _, err := DecodeBytes(bytes.Repeat([]byte("["), 20_000_000)); !errors.Is(err, KindLimit) {
+		t.Errorf("Expected a limit error, got %v", err)
+	}
+
+	deepMap := strings.Repeat(`{"a":`, 2*maxNesting) + "1" + strings.Repeat("}", 2*maxNesting)
+	VerifyLimitError(t, Limits{}, deepMap)
+
+	deepest := strings.Repeat("[", maxNesting) + strings.Repeat("]", maxNesting)
+	if _, err := DecodeFromString(deepest); err != nil {
+		t.Errorf("Unexpected error decoding %v nested arrays: %v", maxNesting, err)
+	}
+}
+
+func TestDecodeBytesReuse(t *testing.T) {
+	for i := 0; i < 3; i++ {
+		if _, err := DecodeBytes([]byte(`["~:abcd", "~#bad"`)); !errors.Is(err, KindSyntax) {
+			t.Errorf("Expected a syntax error, got %v", err)
+		}
+
+		value, err := DecodeBytes([]byte(`["~:abcd", "^0"]`))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		expected := []interface{}{Keyword("abcd"), Keyword("abcd")}
+		if !reflect.DeepEqual(value, expected) {
+			t.Errorf("Expected %v, got %v", expected, value)
+		}
+	}
+}
+
+func collect(d *Decoder) ([]interface{}, []error) {
+	var values []interface{}
+	var errs []error
+	for value, err := range d.All() {
+		if err != nil {
+			errs = append(errs, err)
+		} else {
+			values = append(values, value)
+		}
+	}
+	return values, errs
+}
+
+func TestAll(t *testing.T) {
+	d := NewDecoder(strings.NewReader("[\"~:a\",1]\n\"x\" [\"^ \",\"~:bcd\",\"^0\"]\n\n"))
+	values, errs := collect(d)
+
+	expected := []interface{}{
+		[]interface{}{Keyword("a"), int64(1)},
+		"x",
+		map[interface{}]interface{}{Keyword("bcd"): Keyword("bcd")},
+	}
+	if len(errs) > 0 || !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected %v, got %v %v", expected, values, errs)
+	}
+
+	d = NewDecoder(strings.NewReader(`1 2 3`))
+	for value := range d.All() {
+		if value != int64(1) {
+			t.Errorf("Expected 1, got %v", value)
+		}
+		break
+	}
+	if value, err := d.Decode(); value != int64(2) || err != nil {
+		t.Errorf("Expected to carry on with 2, got %v, %v", value, err)
+	}
+}
+
+func TestAllErrors(t *testing.T) {
+	input := "1\n[2,\n3\n"
+	values, errs := collect(NewDecoder(strings.NewReader(input)))
+
+	if !reflect.DeepEqual(values, []interface{}{int64(1)}) || len(errs) != 1 {
+		t.Fatalf("Expected to stop after the first error, got %v %v", values, errs)
+	}
+	if te := errs[0].(*TransitError); te.Kind != KindSyntax || te.Offset != int64(len(input)) {
+		t.Errorf("Expected a syntax error at the end of the input, got %v at %v", te, te.Offset)
+	}
+}
+
+func TestAllContinueOnError(t *testing.T) {
+	input := "1\n[2,,\n[\"~#unknown\",3]\n[\"~#set\",[4]] [\"^ \",\"a\",[1,2,3,4]]\n5"
+
+	d := NewDecoder(strings.NewReader(input))
+	d.SetContinueOnError(true)
+	d.SetDefaultReadHandler(RejectUnknown)
+	d.SetLimits(Limits{MaxElements: 3})
+
+	values, errs := collect(d)
+
+	if expected := []interface{}{int64(1), MakeSet(int64(4)), int64(5)}; !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected the good values %v, got %v", expected, values)
+	}
+
+	kinds := []ErrorKind{KindSyntax, KindUnknownType, KindLimit}
+	if len(errs) != len(kinds) {
+		t.Fatalf("Expected %v errors, got %v", len(kinds), errs)
+	}
+	for i, kind := range kinds {
+		if !errors.Is(errs[i], kind) {
+			t.Errorf("Expected error %v to be a %v, got %v", i, kind, errs[i])
+		}
+		if errs[i].(*TransitError).Offset == 0 {
+			t.Errorf("Expected error %v to know its offset", i)
+		}
+	}
+}
+
+func TestAllContinueAfterCutShort(t *testing.T) {
+	d := NewDecoder(strings.NewReader("[1,2\n[3]\n[4]\n"))
+	d.SetContinueOnError(true)
+
+	var results []string
+	for value, err := range d.All() {
+		if err != nil {
+			results = append(results, "error")
+		} else {
+			results = append(results, fmt.Sprint(value))
+		}
+	}
+
+	if expected := []string{"error", "[3]", "[4]"}; !reflect.DeepEqual(results, expected) {
+		t.Errorf("Expected %v, got %v", expected, results)
+	}
+}
+
+func TestAllReaderError(t *testing.T) {
+	r := io.MultiReader(strings.NewReader("1\n2\n"), iotest.ErrReader(errDiskFull))
+
+	d := NewDecoder(r)
+	d.SetContinueOnError(true)
+	values, errs := collect(d)
+
+	if len(values) != 2 || len(errs) != 1 || errs[0] != errDiskFull {
+		t.Errorf("Expected two values and the reader's error, got %v %v", values, errs)
+	}
+}
+
+func TestAllAs(t *testing.T) {
+	d := NewDecoder(strings.NewReader("\"~:a\"\n\"b\"\n\"~:c\""))
+
+	var keywords []Keyword
+	var errs []error
+	for k, err := range AllAs[Keyword](d) {
+		if err != nil {
+			errs = append(errs, err)
+		} else {
+			keywords = append(keywords, k)
+		}
+	}
+	if len(keywords) != 1 || len(errs) != 1 || !errors.Is(errs[0], KindType) {
+		t.Errorf("Expected to stop at the string, got %v %v", keywords, errs)
+	}
+
+	d = NewDecoder(strings.NewReader("\"~:a\"\n\"b\"\n\"~:c\""))
+	d.SetContinueOnError(true)
+	keywords, errs = nil, nil
+	for k, err := range AllAs[Keyword](d) {
+		if err != nil {
+			errs = append(errs, err)
+		} else {
+			keywords = append(keywords, k)
+		}
+	}
+	if !reflect.DeepEqual(keywords, []Keyword{"a", "c"}) || len(errs) != 1 {
+		t.Errorf("Expected to skip the string, got %v %v", keywords, errs)
+	}
+}
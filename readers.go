@@ -0,0 +1,155 @@
+// Copyright 2016 Russ Olsen. All Rights Reserved.
+//
+// This code is a Go port of the Java version created and maintained by Cognitect, therefore:
+//
+// Copyright 2014 Cognitect. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS-IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transit
+
+import (
+	"container/list"
+	"reflect"
+)
+
+// ArrayReader builds a collection from the elements of a JSON array
+// as they are read, in place of the default []interface{}. Init
+// starts a new collection, Add adds an element to it and returns the
+// updated collection and Complete returns the finished value.
+type ArrayReader interface {
+	Init() interface{}
+	Add(a interface{}, item interface{}) (interface{}, error)
+	Complete(a interface{}) (interface{}, error)
+}
+
+// MapReader builds a map from its entries as they are read, in place
+// of the default map[interface{}]interface{}. Since every entry is
+// passed to Add, a MapReader also sees duplicate keys.
+type MapReader interface {
+	Init() interface{}
+	Add(m interface{}, key, value interface{}) (interface{}, error)
+	Complete(m interface{}) (interface{}, error)
+}
+
+// readers holds the collection readers of a Decoder. A nil array
+// reader means the default []interface{}.
+type readers struct {
+	array     ArrayReader
+	mapReader MapReader
+	tagArrays map[string]ArrayReader
+	tagMaps   map[string]MapReader
+}
+
+func newReaders() *readers {
+	return &readers{
+		mapReader: genericMapReader{},
+		tagArrays: make(map[string]ArrayReader),
+		tagMaps:   make(map[string]MapReader),
+	}
+}
+
+// genericMapReader builds the default map[interface{}]interface{}.
+type genericMapReader struct{}
+
+func (gr genericMapReader) Init() interface{} {
+	return make(map[interface{}]interface{})
+}
+
+func (gr genericMapReader) Add(m interface{}, key, value interface{}) (interface{}, error) {
+	if !isHashable(key) {
+		return nil, newSyntaxError("Map key cannot be used in a Go map", key, nil)
+	}
+	m.(map[interface{}]interface{})[key] = value
+	return m, nil
+}
+
+func (gr genericMapReader) Complete(m interface{}) (interface{}, error) {
+	return m, nil
+}
+
+// isHashable reports whether x can be used as a key in a Go map.
+func isHashable(x interface{}) bool {
+	t := reflect.TypeOf(x)
+	return t == nil || t.Comparable()
+}
+
+// setReader builds a transit set.
+type setReader struct{}
+
+func (sr setReader) Init() interface{} {
+	return NewSet(nil)
+}
+
+func (sr setReader) Add(a interface{}, item interface{}) (interface{}, error) {
+	set := a.(*Set)
+	set.Contents = append(set.Contents, item)
+	return set, nil
+}
+
+func (sr setReader) Complete(a interface{}) (interface{}, error) {
+	set := a.(*Set)
+	if set.Contents == nil {
+		set.Contents = []interface{}{}
+	}
+	return set, nil
+}
+
+// listReader builds a transit list as a Go list.
+type listReader struct{}
+
+func (lr listReader) Init() interface{} {
+	return list.New()
+}
+
+func (lr listReader) Add(a interface{}, item interface{}) (interface{}, error) {
+	a.(*list.List).PushBack(item)
+	return a, nil
+}
+
+func (lr listReader) Complete(a interface{}) (interface{}, error) {
+	return a, nil
+}
+
+// cmapReader builds a CMap from an array of alternating keys and
+// values.
+type cmapReader struct{}
+
+type cmapBuilder struct {
+	cmap   *CMap
+	key    interface{}
+	hasKey bool
+}
+
+func (cr cmapReader) Init() interface{} {
+	return &cmapBuilder{cmap: NewCMap()}
+}
+
+func (cr cmapReader) Add(a interface{}, item interface{}) (interface{}, error) {
+	b := a.(*cmapBuilder)
+	if b.hasKey {
+		b.cmap.Append(b.key, item)
+		b.key, b.hasKey = nil, false
+	} else {
+		b.key, b.hasKey = item, true
+	}
+	return b, nil
+}
+
+func (cr cmapReader) Complete(a interface{}) (interface{}, error) {
+	b := a.(*cmapBuilder)
+	if b.hasKey {
+		return nil, newSyntaxError("Cmap contents must contain an even number of elements.", b.key, nil)
+	}
+	return b.cmap, nil
+}
@@ -0,0 +1,179 @@
+// Copyright 2016 Russ Olsen. All Rights Reserved.
+//
+// This code is a Go port of the Java version created and maintained by Cognitect, therefore:
+//
+// Copyright 2014 Cognitect. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS-IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transit
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// int64Reader reads arrays straight into []int64.
+type int64Reader struct{}
+
+func (ir int64Reader) Init() interface{} {
+	return &[]int64{}
+}
+
+func (ir int64Reader) Add(a interface{}, item interface{}) (interface{}, error) {
+	i, ok := item.(int64)
+	if !ok {
+		return nil, errors.New("not an integer")
+	}
+	s := a.(*[]int64)
+	*s = append(*s, i)
+	return s, nil
+}
+
+func (ir int64Reader) Complete(a interface{}) (interface{}, error) {
+	return *a.(*[]int64), nil
+}
+
+// sumReader adds up the elements of an array without keeping them.
+type sumReader struct{}
+
+func (sr sumReader) Init() interface{} {
+	return int64(0)
+}
+
+func (sr sumReader) Add(a interface{}, item interface{}) (interface{}, error) {
+	return a.(int64) + item.(int64), nil
+}
+
+func (sr sumReader) Complete(a interface{}) (interface{}, error) {
+	return a, nil
+}
+
+// entriesReader keeps every map entry, in order.
+type entriesReader struct{}
+
+func (er entriesReader) Init() interface{} {
+	return NewCMap()
+}
+
+func (er entriesReader) Add(m interface{}, key, value interface{}) (interface{}, error) {
+	return m.(*CMap).Append(key, value), nil
+}
+
+func (er entriesReader) Complete(m interface{}) (interface{}, error) {
+	return m, nil
+}
+
+func readWith(t *testing.T, s string, setup func(d *Decoder)) interface{} {
+	d := NewDecoder(strings.NewReader(s))
+	setup(d)
+	value, err := d.Decode()
+	if err != nil {
+		t.Fatalf("Unexpected error decoding %v: %v", s, err)
+	}
+	return value
+}
+
+func TestArrayReader(t *testing.T) {
+	value := readWith(t, `[1, 2, 3]`, func(d *Decoder) {
+		d.SetArrayReader(int64Reader{})
+	})
+	if !reflect.DeepEqual(value, []int64{1, 2, 3}) {
+		t.Errorf("Expected []int64{1, 2, 3}, got %#v", value)
+	}
+
+	value = readWith(t, `[]`, func(d *Decoder) {
+		d.SetArrayReader(int64Reader{})
+	})
+	if !reflect.DeepEqual(value, []int64{}) {
+		t.Errorf("Expected an empty []int64, got %#v", value)
+	}
+
+	// Tagged values and maps in array form are not plain arrays.
+	value = readWith(t, `["^ ", "a", {"~#set": [1]}]`, func(d *Decoder) {
+		d.SetArrayReader(int64Reader{})
+	})
+	set := value.(map[interface{}]interface{})["a"].(*Set)
+	assertTrue(t, set.ContainsEq(int64(1)))
+}
+
+func TestMapReader(t *testing.T) {
+	value := readWith(t, `{"a": 1, "b": 2, "a": 3}`, func(d *Decoder) {
+		d.SetMapReader(entriesReader{})
+	})
+
+	entries := value.(*CMap).Entries
+	if len(entries) != 3 || entries[2].Key != "a" || entries[2].Value != int64(3) {
+		t.Errorf("Expected all three entries, in order, got %v", entries)
+	}
+
+	value = readWith(t, `["^ ", "~:abcd", 1, "~:efgh", ["^ ", "^0", 2]]`, func(d *Decoder) {
+		d.SetMapReader(entriesReader{})
+	})
+
+	entries = value.(*CMap).Entries
+	inner := entries[1].Value.(*CMap).Entries
+	assertEquals(t, Keyword("abcd"), inner[0].Key)
+	assertEquals(t, int64(2), inner[0].Value)
+
+	value = readWith(t, `{"a": 1}`, func(d *Decoder) {
+		d.SetMapReader(entriesReader{})
+		d.SetMapReader(nil)
+	})
+	assertEquals(t, int64(1), value.(map[interface{}]interface{})["a"])
+}
+
+func TestTagReaders(t *testing.T) {
+	handlerCalled := false
+
+	value := readWith(t, `[{"~#total": [1, 2, 3]}, ["~#total", []], {"~#total": "~i5"}]`, func(d *Decoder) {
+		d.AddHandler("total", func(d Decoder, x interface{}) (interface{}, error) {
+			handlerCalled = true
+			return x.(TaggedValue).Value, nil
+		})
+		d.AddArrayReader("total", sumReader{})
+	})
+
+	if !reflect.DeepEqual(value, []interface{}{int64(6), int64(0), int64(5)}) {
+		t.Errorf("Expected the totals to be added up, got %v", value)
+	}
+	if !handlerCalled {
+		t.Errorf("Expected the handler to be called for a value that is not an array")
+	}
+
+	value = readWith(t, `["~#entries", ["^ ", "a", 1, "a", 2]]`, func(d *Decoder) {
+		d.AddMapReader("entries", entriesReader{})
+	})
+	assertEquals(t, 2, value.(*CMap).Size())
+
+	// A handler replaces the built in reader for sets.
+	value = readWith(t, `{"~#set": [1, 2]}`, func(d *Decoder) {
+		d.AddHandler("set", func(d Decoder, x interface{}) (interface{}, error) {
+			return len(x.(TaggedValue).Value.([]interface{})), nil
+		})
+	})
+	assertEquals(t, 2, value)
+}
+
+func TestReaderErrors(t *testing.T) {
+	d := NewDecoder(strings.NewReader(`["^ ", "a", [1, 2, "x"]]`))
+	d.SetArrayReader(int64Reader{})
+
+	_, err := d.Decode()
+	VerifyError(t, err, KindHandler, "[a 2]")
+
+	_, err = DecodeFromString(`{"~#cmap": [1, 2, 3]}`)
+	VerifyError(t, err, KindSyntax, "[]")
+}
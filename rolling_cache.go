@@ -52,6 +52,13 @@ func (rc *RollingCache) Read(name string) string {
 	return rc.keyToValue[name]
 }
 
+// lookup is like Read, but takes the cache code as bytes straight
+// from the decoder's input, which saves converting it to a string.
+func (rc *RollingCache) lookup(code []byte) (string, bool) {
+	value, present := rc.keyToValue[string(code)]
+	return value, present
+}
+
 // Enter the name into the cache if it passes the cacheable critieria.
 // Returns either the name or the value that was previously cached for
 // the name.
@@ -103,9 +110,9 @@ func (rc *RollingCache) encodeKey(index int) string {
 	var hi = index / cacheCodeDigits
 	var lo = index % cacheCodeDigits
 	if hi == 0 {
-		return sub + string(lo+baseCharIndex)
+		return sub + string(rune(lo+baseCharIndex))
 	} else {
-		return sub + string(hi+baseCharIndex) + string(lo+baseCharIndex)
+		return sub + string(rune(hi+baseCharIndex)) + string(rune(lo+baseCharIndex))
 	}
 }
 
@@ -123,6 +130,6 @@ func (rc *RollingCache) isCacheFull() bool {
 }
 
 func (rc *RollingCache) Clear() {
-	rc.valueToKey = make(StringMap)
-	rc.keyToValue = make(StringMap)
+	clear(rc.valueToKey)
+	clear(rc.keyToValue)
 }
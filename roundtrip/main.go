@@ -1,9 +1,7 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
-	"io"
 	"os"
 	//	"reflect"
 	"github.com/russolsen/transit"
@@ -11,30 +9,7 @@ import (
 
 var logf, _ = os.OpenFile("/tmp/log.txt", os.O_RDWR|os.O_TRUNC|os.O_CREATE, 0666)
 
-func ReadTransit(jsd *json.Decoder) interface{} {
-	decoder := transit.NewJsonDecoder(jsd)
-
-	fmt.Fprintf(logf, "Reading...")
-
-	value, err := decoder.Decode()
-
-	fmt.Fprintf(logf, "Value read:\n%v\n", value)
-
-	//fmt.Printf("The value read is: %v[%v]\n", value, reflect.TypeOf(value))
-
-	if err == io.EOF {
-		return err
-	} else if err != nil {
-		fmt.Fprintf(logf, "Error reading Transit data: %v\n", err)
-		return io.EOF
-	}
-
-	return value
-}
-
-func WriteTransit(value interface{}) {
-	encoder := transit.NewEncoder(os.Stdout, false)
-
+func WriteTransit(encoder *transit.Encoder, value interface{}) {
 	fmt.Fprintf(logf, "Writing...")
 	err := encoder.Encode(value)
 
@@ -45,11 +20,21 @@ func WriteTransit(value interface{}) {
 }
 
 func main() {
+	decoder := transit.NewDecoder(os.Stdin)
+	encoder := transit.NewEncoder(os.Stdout, false)
+
+	for value, err := range decoder.All() {
+		if err != nil {
+			fmt.Fprintf(logf, "Error reading Transit data: %v\n", err)
+			fmt.Fprintf(os.Stderr, "Error reading Transit data: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Fprintf(logf, "Value read:\n%v\n", value)
 
-	jsd := json.NewDecoder(os.Stdin)
+		//fmt.Printf("The value read is: %v[%v]\n", value, reflect.TypeOf(value))
 
-	for x := ReadTransit(jsd); x != io.EOF; x = ReadTransit(jsd) {
-		WriteTransit(x)
+		WriteTransit(encoder, value)
 		os.Stdout.Sync()
 	}
 	fmt.Fprintf(logf, "Done!")
@@ -0,0 +1,168 @@
+// Copyright 2016 Russ Olsen. All Rights Reserved.
+//
+// This code is a Go port of the Java version created and maintained by Cognitect, therefore:
+//
+// Copyright 2014 Cognitect. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS-IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transit
+
+import (
+	"errors"
+	"io"
+	"net/rpc"
+)
+
+// The codecs below carry net/rpc calls as transit, one value per
+// message, so that clients written in other languages need nothing
+// more than a transit reader and writer. A request is a map
+//
+//	{:method "Service.Method", :id 1, :params <args>}
+//
+// and the response is either {:id 1, :result <reply>} or
+// {:id 1, :error "message"}. Arguments and replies are encoded with
+// struct mapping on and decoded with DecodeInto, so keywords, sets,
+// big decimals and the like come through as themselves.
+
+var (
+	rpcMethod = Keyword("method")
+	rpcId     = Keyword("id")
+	rpcParams = Keyword("params")
+	rpcResult = Keyword("result")
+	rpcError  = Keyword("error")
+)
+
+// newRPCEncoder returns the encoder the codecs write with.
+func newRPCEncoder(w io.Writer) *Encoder {
+	e := NewEncoder(w, false)
+	e.SetStructMapping(true)
+	return e
+}
+
+// readRPCMessage reads the next message from d. net/rpc compares
+// errors with io.EOF and io.ErrUnexpectedEOF to tell a peer hanging up
+// from a broken one, so those come back unwrapped.
+func readRPCMessage(d *Decoder) (map[interface{}]interface{}, uint64, error) {
+	m, err := DecodeAs[map[interface{}]interface{}](d)
+	switch {
+	case err == nil:
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return nil, 0, io.ErrUnexpectedEOF
+	case errors.Is(err, io.EOF):
+		return nil, 0, io.EOF
+	default:
+		return nil, 0, err
+	}
+	id, err := Get[int64](m, rpcId)
+	if err != nil {
+		return nil, 0, err
+	}
+	return m, uint64(id), nil
+}
+
+type clientCodec struct {
+	conn   io.Closer
+	enc    *Encoder
+	dec    *Decoder
+	result interface{}
+}
+
+// NewClientCodec returns a net/rpc ClientCodec that talks transit
+// over conn. Use it with rpc.NewClientWithCodec.
+func NewClientCodec(conn io.ReadWriteCloser) rpc.ClientCodec {
+	return &clientCodec{conn: conn, enc: newRPCEncoder(conn), dec: NewDecoder(conn)}
+}
+
+func (c *clientCodec) WriteRequest(r *rpc.Request, params interface{}) error {
+	return c.enc.Encode(map[Keyword]interface{}{
+		rpcMethod: r.ServiceMethod,
+		rpcId:     r.Seq,
+		rpcParams: params,
+	})
+}
+
+func (c *clientCodec) ReadResponseHeader(r *rpc.Response) error {
+	c.result = nil
+	m, id, err := readRPCMessage(c.dec)
+	if err != nil {
+		return err
+	}
+
+	r.Seq = id
+	r.Error = ""
+	if msg, ok := m[rpcError].(string); ok {
+		r.Error = msg
+	}
+	c.result = m[rpcResult]
+	return nil
+}
+
+func (c *clientCodec) ReadResponseBody(reply interface{}) error {
+	if reply == nil {
+		return nil
+	}
+	return assignTo(reply, c.result)
+}
+
+func (c *clientCodec) Close() error {
+	return c.conn.Close()
+}
+
+type serverCodec struct {
+	conn   io.Closer
+	enc    *Encoder
+	dec    *Decoder
+	params interface{}
+}
+
+// NewServerCodec returns a net/rpc ServerCodec that talks transit
+// over conn. Use it with rpc.ServeCodec.
+func NewServerCodec(conn io.ReadWriteCloser) rpc.ServerCodec {
+	return &serverCodec{conn: conn, enc: newRPCEncoder(conn), dec: NewDecoder(conn)}
+}
+
+func (c *serverCodec) ReadRequestHeader(r *rpc.Request) error {
+	c.params = nil
+	m, id, err := readRPCMessage(c.dec)
+	if err != nil {
+		return err
+	}
+	method, err := Get[string](m, rpcMethod)
+	if err != nil {
+		return err
+	}
+
+	r.ServiceMethod = method
+	r.Seq = id
+	c.params = m[rpcParams]
+	return nil
+}
+
+func (c *serverCodec) ReadRequestBody(params interface{}) error {
+	if params == nil {
+		return nil
+	}
+	return assignTo(params, c.params)
+}
+
+func (c *serverCodec) WriteResponse(r *rpc.Response, reply interface{}) error {
+	if r.Error != "" {
+		return c.enc.Encode(map[Keyword]interface{}{rpcId: r.Seq, rpcError: r.Error})
+	}
+	return c.enc.Encode(map[Keyword]interface{}{rpcId: r.Seq, rpcResult: reply})
+}
+
+func (c *serverCodec) Close() error {
+	return c.conn.Close()
+}
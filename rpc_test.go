@@ -0,0 +1,215 @@
+// Copyright 2016 Russ Olsen. All Rights Reserved.
+//
+// This code is a Go port of the Java version created and maintained by Cognitect, therefore:
+//
+// Copyright 2014 Cognitect. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS-IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transit
+
+import (
+	"errors"
+	"github.com/shopspring/decimal"
+	"io"
+	"net"
+	"net/rpc"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type LedgerTransfer struct {
+	From   Keyword         `transit:"from"`
+	To     Keyword         `transit:"to"`
+	Amount decimal.Decimal `transit:"amount"`
+	Tags   *Set            `transit:"tags"`
+}
+
+type LedgerReceipt struct {
+	Accounts *Set            `transit:"accounts"`
+	Amount   decimal.Decimal `transit:"amount"`
+	Tags     []Keyword       `transit:"tags"`
+}
+
+type Ledger struct{}
+
+func (Ledger) Transfer(args LedgerTransfer, reply *LedgerReceipt) error {
+	if args.Amount.IsNegative() {
+		return errors.New("negative amount")
+	}
+	reply.Accounts = MakeSet(args.From, args.To)
+	reply.Amount = args.Amount
+	for _, tag := range args.Tags.Contents {
+		reply.Tags = append(reply.Tags, tag.(Keyword))
+	}
+	return nil
+}
+
+func newLedgerClient(t *testing.T) *rpc.Client {
+	server := rpc.NewServer()
+	if err := server.Register(Ledger{}); err != nil {
+		t.Fatal(err)
+	}
+
+	clientConn, serverConn := net.Pipe()
+	go server.ServeCodec(NewServerCodec(serverConn))
+
+	client := rpc.NewClientWithCodec(NewClientCodec(clientConn))
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestRPC(t *testing.T) {
+	client := newLedgerClient(t)
+
+	args := LedgerTransfer{
+		From:   Keyword("alice"),
+		To:     Keyword("bob"),
+		Amount: decimal.RequireFromString("10.25"),
+		Tags:   MakeSet(Keyword("rent")),
+	}
+	var reply LedgerReceipt
+	if err := client.Call("Ledger.Transfer", args, &reply); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reply.Amount.Equal(args.Amount) {
+		t.Errorf("Expected amount %v, got %v", args.Amount, reply.Amount)
+	}
+	if !reflect.DeepEqual(reply.Accounts, MakeSet(Keyword("alice"), Keyword("bob"))) {
+		t.Errorf("Expected accounts #{:alice :bob}, got %v", reply.Accounts)
+	}
+	if !reflect.DeepEqual(reply.Tags, []Keyword{"rent"}) {
+		t.Errorf("Expected tags [:rent], got %v", reply.Tags)
+	}
+}
+
+func TestRPCErrors(t *testing.T) {
+	client := newLedgerClient(t)
+
+	args := LedgerTransfer{Amount: decimal.NewFromInt(-1), Tags: MakeSet()}
+	err := client.Call("Ledger.Transfer", args, &LedgerReceipt{})
+	if err == nil || err.Error() != "negative amount" {
+		t.Errorf("Expected the service's error, got %v", err)
+	}
+
+	if err := client.Call("Ledger.Nothing", args, &LedgerReceipt{}); err == nil {
+		t.Errorf("Expected an error for an unknown method")
+	}
+
+	// Arguments that don't fit are reported, and the connection
+	// stays usable.
+	err = client.Call("Ledger.Transfer", map[Keyword]interface{}{"amount": "lots"}, &LedgerReceipt{})
+	if err == nil {
+		t.Errorf("Expected an error for bad arguments")
+	}
+
+	var reply LedgerReceipt
+	args.Amount = decimal.NewFromInt(1)
+	if err := client.Call("Ledger.Transfer", args, &reply); err != nil || !reply.Amount.Equal(args.Amount) {
+		t.Errorf("Expected a receipt for 1, got %v, %v", reply, err)
+	}
+}
+
+func TestRPCConcurrentCalls(t *testing.T) {
+	client := newLedgerClient(t)
+
+	calls := make([]*rpc.Call, 10)
+	for i := range calls {
+		args := LedgerTransfer{Amount: decimal.NewFromInt(int64(i)), Tags: MakeSet()}
+		calls[i] = client.Go("Ledger.Transfer", args, &LedgerReceipt{}, nil)
+	}
+	for i, call := range calls {
+		<-call.Done
+		if call.Error != nil {
+			t.Fatal(call.Error)
+		}
+		if amount := call.Reply.(*LedgerReceipt).Amount; amount.IntPart() != int64(i) {
+			t.Errorf("Expected %v, got %v", i, amount)
+		}
+	}
+}
+
+func TestRPCDisconnect(t *testing.T) {
+	for input, expected := range map[string]error{
+		"":                             io.EOF,
+		`["^ ","~:method","Ledger.Tra`: io.ErrUnexpectedEOF,
+	} {
+		server := NewServerCodec(readOnlyConn{strings.NewReader(input)})
+		if err := server.ReadRequestHeader(&rpc.Request{}); err != expected {
+			t.Errorf("%q: expected %v, got %#v", input, expected, err)
+		}
+
+		client := NewClientCodec(readOnlyConn{strings.NewReader(input)})
+		if err := client.ReadResponseHeader(&rpc.Response{}); err != expected {
+			t.Errorf("%q: expected %v, got %#v", input, expected, err)
+		}
+	}
+}
+
+// readOnlyConn is a connection that reads from a fixed input.
+type readOnlyConn struct {
+	io.Reader
+}
+
+func (readOnlyConn) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+func (readOnlyConn) Close() error {
+	return nil
+}
+
+// TestRPCWire talks to the server the way a client in another
+// language would, with nothing but transit maps.
+func TestRPCWire(t *testing.T) {
+	server := rpc.NewServer()
+	server.Register(Ledger{})
+
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	go server.ServeCodec(NewServerCodec(serverConn))
+
+	request := map[Keyword]interface{}{
+		"method": "Ledger.Transfer",
+		"id":     7,
+		"params": map[Keyword]interface{}{
+			"from":   Keyword("alice"),
+			"to":     Keyword("bob"),
+			"amount": decimal.RequireFromString("2.50"),
+			"tags":   MakeSet(),
+		},
+	}
+	go NewEncoder(clientConn, false).Encode(request)
+
+	response, err := DecodeAs[map[interface{}]interface{}](NewDecoder(clientConn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, _ := Get[int64](response, Keyword("id")); id != 7 {
+		t.Errorf("Expected id 7, got %v", response)
+	}
+
+	result, err := Get[map[interface{}]interface{}](response, Keyword("result"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if amount, _ := Get[decimal.Decimal](result, Keyword("amount")); amount.String() != "2.5" {
+		t.Errorf("Expected a decimal amount of 2.5, got %v", result)
+	}
+	accounts, err := Get[*Set](result, Keyword("accounts"))
+	if err != nil || !accounts.ContainsEq(Keyword("alice")) || !accounts.ContainsEq(Keyword("bob")) {
+		t.Errorf("Expected a set of keywords, got %v, %v", result, err)
+	}
+}
@@ -0,0 +1,458 @@
+// Copyright 2016 Russ Olsen. All Rights Reserved.
+//
+// This code is a Go port of the Java version created and maintained by Cognitect, therefore:
+//
+// Copyright 2014 Cognitect. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS-IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transit
+
+import (
+	"bytes"
+	"io"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// scanner reads JSON tokens, either from a stream or straight out of
+// a byte slice. It knows nothing about transit: the Decoder asks it
+// for one token at a time and does the transit parsing as it goes.
+type scanner struct {
+	r       io.Reader // nil when scanning a byte slice
+	buf     []byte
+	pos     int    // position of the next unread byte in buf
+	offset  int64  // stream offset of buf[0]
+	err     error  // the error that stopped the reader
+	scratch []byte // used to unescape strings
+	limits  *limiter
+
+	// newline is set when peek skips a newline, and cleared at the
+	// start of each top level value.
+	newline bool
+}
+
+// scanBufferSize is the size of the read buffer a Decoder starts
+// with. It costs a one-off 4K per Decoder, which a stream of values
+// shares, in return for reading in large chunks. DecodeBytes scans
+// its input in place and has no buffer at all.
+const scanBufferSize = 4096
+
+// maxEmptyReads is the number of times in a row a reader may return
+// no data and no error before the scanner gives up on it.
+const maxEmptyReads = 100
+
+func newScanner(r io.Reader, limits *limiter) *scanner {
+	return &scanner{r: r, buf: make([]byte, 0, scanBufferSize), limits: limits}
+}
+
+func newBytesScanner(b []byte, limits *limiter) *scanner {
+	return &scanner{buf: b, err: io.EOF, limits: limits}
+}
+
+// resetBytes makes the scanner start over on b.
+func (s *scanner) resetBytes(b []byte) {
+	*s = scanner{buf: b, err: io.EOF, scratch: s.scratch[:0], limits: s.limits}
+}
+
+// Offset returns the stream offset of the next unread byte.
+func (s *scanner) Offset() int64 {
+	return s.offset + int64(s.pos)
+}
+
+// fill reads more input into the buffer, keeping everything from
+// s.pos on. It returns false if there is no more input.
+func (s *scanner) fill() bool {
+	if s.err != nil {
+		return false
+	}
+
+	if s.pos > 0 {
+		n := copy(s.buf, s.buf[s.pos:])
+		s.buf = s.buf[:n]
+		s.offset += int64(s.pos)
+		s.pos = 0
+	}
+
+	if len(s.buf) == cap(s.buf) {
+		newBuf := make([]byte, len(s.buf), 2*cap(s.buf))
+		copy(newBuf, s.buf)
+		s.buf = newBuf
+	}
+
+	space := s.buf[len(s.buf):cap(s.buf)]
+
+	if max := s.limits.MaxBytes; max > 0 {
+		remaining := max - s.offset - int64(len(s.buf))
+		if remaining <= 0 {
+			s.err = newLimitError("Input size", max, nil)
+			return false
+		}
+		if int64(len(space)) > remaining {
+			space = space[:remaining]
+		}
+	}
+
+	for i := 0; i < maxEmptyReads; i++ {
+		n, err := s.r.Read(space)
+		s.buf = s.buf[:len(s.buf)+n]
+		if err != nil {
+			s.err = err
+		}
+		if n > 0 {
+			return true
+		}
+		if err != nil {
+			return false
+		}
+	}
+	s.err = io.ErrNoProgress
+	return false
+}
+
+// checkSize makes sure that a byte slice is within MaxBytes.
+func (s *scanner) checkSize() error {
+	if max := s.limits.MaxBytes; max > 0 && s.r == nil && int64(len(s.buf)) > max {
+		return newLimitError("Input size", max, len(s.buf))
+	}
+	return nil
+}
+
+// syntaxError returns a syntax error for the current position.
+func (s *scanner) syntaxError(msg string, err error) *TransitError {
+	e := newSyntaxError(msg, nil, err)
+	e.Offset = s.Offset()
+	return e
+}
+
+// locate records the current offset in err, if it is a TransitError
+// that does not know where it happened.
+func (s *scanner) locate(err error) error {
+	if te, ok := err.(*TransitError); ok && te.Offset == 0 {
+		return te.at(s.Offset())
+	}
+	return err
+}
+
+// skipLine discards what is left of the line that the current value
+// failed on. If the value has already gone on to another line there is
+// nothing left, and the next value starts where the error was found.
+func (s *scanner) skipLine() {
+	if s.newline {
+		return
+	}
+	for {
+		if i := bytes.IndexByte(s.buf[s.pos:], '\n'); i >= 0 {
+			s.pos += i + 1
+			return
+		}
+		s.pos = len(s.buf)
+		if !s.fill() {
+			return
+		}
+	}
+}
+
+// unexpectedEnd returns the error for running out of input in the
+// middle of a value.
+func (s *scanner) unexpectedEnd() error {
+	if s.err != nil && s.err != io.EOF {
+		return s.err
+	}
+	return s.syntaxError("Unexpected end of input", io.ErrUnexpectedEOF)
+}
+
+// peek skips white space and returns the next byte without
+// consuming it. At the end of the input it returns io.EOF.
+func (s *scanner) peek() (byte, error) {
+	for {
+		for s.pos < len(s.buf) {
+			switch c := s.buf[s.pos]; c {
+			case '\n':
+				s.newline = true
+				s.pos++
+			case ' ', '\t', '\r':
+				s.pos++
+			default:
+				return c, nil
+			}
+		}
+		if !s.fill() {
+			if s.err == nil {
+				return 0, io.EOF
+			}
+			return 0, s.err
+		}
+	}
+}
+
+// next is like peek, except that running out of input is an error.
+func (s *scanner) next() (byte, error) {
+	c, err := s.peek()
+	if err == io.EOF {
+		return 0, s.unexpectedEnd()
+	}
+	return c, err
+}
+
+// expect consumes the next byte, which must be c.
+func (s *scanner) expect(c byte) error {
+	actual, err := s.next()
+	if err != nil {
+		return err
+	}
+	if actual != c {
+		return s.syntaxError("Expected '"+string(c)+"' but found '"+string(actual)+"'", nil)
+	}
+	s.pos++
+	return nil
+}
+
+// readString reads a JSON string, which must start at the next byte.
+// The result points into the scanner's buffers and is only good
+// until the next call.
+func (s *scanner) readString() ([]byte, error) {
+	n := 1
+	escaped := false
+	ascii := true
+
+	for {
+		if s.pos+n >= len(s.buf) {
+			if err := s.limits.checkLength(n - 1); err != nil {
+				return nil, err
+			}
+			if !s.fill() {
+				return nil, s.unexpectedEnd()
+			}
+			continue
+		}
+
+		c := s.buf[s.pos+n]
+
+		switch {
+		case c == '"':
+			raw := s.buf[s.pos+1 : s.pos+n]
+			if err := s.limits.checkLength(len(raw)); err != nil {
+				return nil, err
+			}
+			s.pos += n + 1
+			if !escaped && (ascii || utf8.Valid(raw)) {
+				return raw, nil
+			}
+			return s.unquote(raw)
+		case c == '\\':
+			escaped = true
+			n += 2
+		case c < 0x20:
+			s.pos += n
+			return nil, s.syntaxError("Control character in string", nil)
+		default:
+			if c >= utf8.RuneSelf {
+				ascii = false
+			}
+			n++
+		}
+	}
+}
+
+// unquote turns the raw bytes of a JSON string into the string they
+// represent. Bad UTF-8 and bad surrogates become U+FFFD, just as with
+// encoding/json.
+func (s *scanner) unquote(raw []byte) ([]byte, error) {
+	out := s.scratch[:0]
+
+	for i := 0; i < len(raw); {
+		c := raw[i]
+
+		switch {
+		case c == '\\':
+			if i+1 >= len(raw) {
+				return nil, s.syntaxError("Bad escape in string", nil)
+			}
+			switch raw[i+1] {
+			case '"', '\\', '/':
+				out = append(out, raw[i+1])
+			case 'b':
+				out = append(out, '\b')
+			case 'f':
+				out = append(out, '\f')
+			case 'n':
+				out = append(out, '\n')
+			case 'r':
+				out = append(out, '\r')
+			case 't':
+				out = append(out, '\t')
+			case 'u':
+				r, ok := hex4(raw[i+2:])
+				if !ok {
+					return nil, s.syntaxError("Bad unicode escape in string", nil)
+				}
+				i += 6
+				if utf16.IsSurrogate(r) {
+					decoded := unicode.ReplacementChar
+					if i+6 <= len(raw) && raw[i] == '\\' && raw[i+1] == 'u' {
+						if r2, ok := hex4(raw[i+2:]); ok {
+							if decoded = utf16.DecodeRune(r, r2); decoded != unicode.ReplacementChar {
+								i += 6
+							}
+						}
+					}
+					r = decoded
+				}
+				out = utf8.AppendRune(out, r)
+				continue
+			default:
+				return nil, s.syntaxError("Bad escape in string", nil)
+			}
+			i += 2
+		case c < utf8.RuneSelf:
+			out = append(out, c)
+			i++
+		default:
+			r, size := utf8.DecodeRune(raw[i:])
+			if r == utf8.RuneError && size == 1 {
+				out = utf8.AppendRune(out, unicode.ReplacementChar)
+			} else {
+				out = append(out, raw[i:i+size]...)
+			}
+			i += size
+		}
+	}
+
+	s.scratch = out
+	return out, nil
+}
+
+// hex4 decodes the four hex digits at the start of b.
+func hex4(b []byte) (rune, bool) {
+	if len(b) < 4 {
+		return 0, false
+	}
+	var r rune
+	for _, c := range b[:4] {
+		switch {
+		case '0' <= c && c <= '9':
+			c = c - '0'
+		case 'a' <= c && c <= 'f':
+			c = c - 'a' + 10
+		case 'A' <= c && c <= 'F':
+			c = c - 'A' + 10
+		default:
+			return 0, false
+		}
+		r = r*16 + rune(c)
+	}
+	return r, true
+}
+
+// readNumber reads a JSON number. Like readString, the result is only
+// good until the next call. The second result is true if the number
+// is an integer.
+func (s *scanner) readNumber() ([]byte, bool, error) {
+	n := 0
+	integer := true
+
+	for {
+		if s.pos+n >= len(s.buf) {
+			if err := s.limits.checkDigits(n); err != nil {
+				return nil, false, err
+			}
+			if !s.fill() {
+				if s.err != nil && s.err != io.EOF {
+					return nil, false, s.err
+				}
+				break
+			}
+			continue
+		}
+
+		c := s.buf[s.pos+n]
+		if c == '.' || c == 'e' || c == 'E' {
+			integer = false
+		} else if !(c >= '0' && c <= '9') && c != '-' && c != '+' {
+			break
+		}
+		n++
+	}
+
+	number := s.buf[s.pos : s.pos+n]
+
+	if !validNumber(number) {
+		return nil, false, s.syntaxError("Bad number '"+string(number)+"'", nil)
+	}
+	if err := s.limits.checkDigits(n); err != nil {
+		return nil, false, err
+	}
+
+	s.pos += n
+	return number, integer, nil
+}
+
+// validNumber checks b against the JSON number grammar.
+func validNumber(b []byte) bool {
+	i := 0
+	if i < len(b) && b[i] == '-' {
+		i++
+	}
+	if i >= len(b) {
+		return false
+	}
+	if b[i] == '0' {
+		i++
+	} else if b[i] >= '1' && b[i] <= '9' {
+		for i < len(b) && b[i] >= '0' && b[i] <= '9' {
+			i++
+		}
+	} else {
+		return false
+	}
+	if i < len(b) && b[i] == '.' {
+		i++
+		start := i
+		for i < len(b) && b[i] >= '0' && b[i] <= '9' {
+			i++
+		}
+		if i == start {
+			return false
+		}
+	}
+	if i < len(b) && (b[i] == 'e' || b[i] == 'E') {
+		i++
+		if i < len(b) && (b[i] == '+' || b[i] == '-') {
+			i++
+		}
+		start := i
+		for i < len(b) && b[i] >= '0' && b[i] <= '9' {
+			i++
+		}
+		if i == start {
+			return false
+		}
+	}
+	return i == len(b)
+}
+
+// readLiteral consumes one of the JSON literals true, false and null.
+func (s *scanner) readLiteral(literal string) error {
+	for s.pos+len(literal) > len(s.buf) {
+		if !s.fill() {
+			return s.unexpectedEnd()
+		}
+	}
+	if string(s.buf[s.pos:s.pos+len(literal)]) != literal {
+		return s.syntaxError("Unexpected character '"+string(s.buf[s.pos])+"'", nil)
+	}
+	s.pos += len(literal)
+	return nil
+}
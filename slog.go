@@ -0,0 +1,250 @@
+// Copyright 2016 Russ Olsen. All Rights Reserved.
+//
+// This code is a Go port of the Java version created and maintained by Cognitect, therefore:
+//
+// Copyright 2014 Cognitect. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS-IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transit
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// SlogHandler is a slog.Handler that writes each record as a transit
+// map, one to a line. The keys are keywords: :time, :level and :msg,
+// :source if asked for, and one for each attribute. Groups become
+// nested maps. Times are written as transit times, levels as
+// keywords such as :info, and other values as themselves, so that
+// keywords, UUIDs, big decimals and so on keep their types. Structs
+// go through the struct mapping, and the encoder's DefaultFallbacks
+// handle other types. Durations are written as integer nanoseconds
+// and errors as their messages.
+type SlogHandler struct {
+	out    *slogOutput
+	opts   slog.HandlerOptions
+	groups []string
+	attrs  []groupedAttr
+}
+
+// slogOutput is shared by a handler and the handlers made from it
+// with WithAttrs and WithGroup.
+type slogOutput struct {
+	mu  sync.Mutex
+	w   io.Writer
+	buf bytes.Buffer
+	enc *Encoder
+}
+
+// groupedAttr is an attribute added by WithAttrs, along with the
+// groups that were open at the time.
+type groupedAttr struct {
+	groups []string
+	attr   slog.Attr
+}
+
+// NewSlogHandler returns a SlogHandler that writes to w. A nil opts
+// means the default options.
+func NewSlogHandler(w io.Writer, opts *slog.HandlerOptions) *SlogHandler {
+	out := &slogOutput{w: w}
+	out.enc = NewEncoder(&out.buf, false)
+	out.enc.SetStructMapping(true)
+	out.enc.SetFallbacks(DefaultFallbacks)
+
+	h := &SlogHandler{out: out}
+	if opts != nil {
+		h.opts = *opts
+	}
+	return h
+}
+
+func (h *SlogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	min := slog.LevelInfo
+	if h.opts.Level != nil {
+		min = h.opts.Level.Level()
+	}
+	return level >= min
+}
+
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	h2 := *h
+	h2.attrs = slices.Clip(h.attrs)
+	for _, a := range attrs {
+		h2.attrs = append(h2.attrs, groupedAttr{groups: h.groups, attr: a})
+	}
+	return &h2
+}
+
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.groups = append(slices.Clip(h.groups), name)
+	return &h2
+}
+
+// Handle writes r. If one of its values cannot be encoded, the
+// record is encoded again with every value of an arbitrary type
+// turned into a string, rather than lose it. Errors from the writer
+// are returned as they are.
+func (h *SlogHandler) Handle(ctx context.Context, r slog.Record) error {
+	encodeErr, err := h.out.write(h.record(r, false))
+	if encodeErr != nil {
+		if encodeErr, err = h.out.write(h.record(r, true)); encodeErr != nil {
+			return encodeErr
+		}
+	}
+	return err
+}
+
+// write encodes m and writes it out. If m cannot be encoded nothing is
+// written and the encoder's error is returned as encodeErr; otherwise
+// err is the writer's error.
+func (o *slogOutput) write(m map[Keyword]interface{}) (encodeErr, err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.buf.Reset()
+	if err := o.enc.Encode(m); err != nil {
+		o.enc.Reset(&o.buf)
+		return err, nil
+	}
+	o.buf.WriteByte('\n')
+	_, err = o.w.Write(o.buf.Bytes())
+	return nil, err
+}
+
+// slogRecord builds the map for a record.
+type slogRecord struct {
+	h         *SlogHandler
+	m         map[Keyword]interface{}
+	stringify bool
+}
+
+func (h *SlogHandler) record(r slog.Record, stringify bool) map[Keyword]interface{} {
+	b := slogRecord{h: h, m: make(map[Keyword]interface{}, 4+r.NumAttrs()), stringify: stringify}
+
+	if !r.Time.IsZero() {
+		b.add(nil, slog.Time(slog.TimeKey, r.Time))
+	}
+	b.add(nil, slog.Any(slog.LevelKey, r.Level))
+	if h.opts.AddSource && r.PC != 0 {
+		b.add(nil, slog.Any(slog.SourceKey, source(r.PC)))
+	}
+	b.add(nil, slog.String(slog.MessageKey, r.Message))
+
+	for _, ga := range h.attrs {
+		b.add(ga.groups, ga.attr)
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		b.add(h.groups, a)
+		return true
+	})
+	return b.m
+}
+
+func source(pc uintptr) *slog.Source {
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	return &slog.Source{Function: frame.Function, File: frame.File, Line: frame.Line}
+}
+
+// add puts a into the map of the innermost of groups, after passing
+// it through ReplaceAttr. Empty attributes and groups are left out.
+func (b *slogRecord) add(groups []string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if replace := b.h.opts.ReplaceAttr; replace != nil && a.Value.Kind() != slog.KindGroup {
+		a = replace(groups, a)
+		a.Value = a.Value.Resolve()
+	}
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			groups = append(slices.Clip(groups), a.Key)
+		}
+		for _, ga := range a.Value.Group() {
+			b.add(groups, ga)
+		}
+		return
+	}
+
+	b.group(groups)[Keyword(a.Key)] = b.value(a.Value)
+}
+
+// group returns the map for the given groups, creating it if need be.
+func (b *slogRecord) group(groups []string) map[Keyword]interface{} {
+	m := b.m
+	for _, name := range groups {
+		inner, ok := m[Keyword(name)].(map[Keyword]interface{})
+		if !ok {
+			inner = make(map[Keyword]interface{})
+			m[Keyword(name)] = inner
+		}
+		m = inner
+	}
+	return m
+}
+
+func (b *slogRecord) value(v slog.Value) interface{} {
+	switch v.Kind() {
+	case slog.KindString:
+		return v.String()
+	case slog.KindInt64:
+		return v.Int64()
+	case slog.KindUint64:
+		return v.Uint64()
+	case slog.KindFloat64:
+		return v.Float64()
+	case slog.KindBool:
+		return v.Bool()
+	case slog.KindDuration:
+		return v.Duration().Nanoseconds()
+	case slog.KindTime:
+		return v.Time()
+	}
+
+	switch x := v.Any().(type) {
+	case nil:
+		return nil
+	case slog.Level:
+		return Keyword(strings.ToLower(x.String()))
+	case *slog.Source:
+		return map[Keyword]interface{}{
+			Keyword("function"): x.Function,
+			Keyword("file"):     x.File,
+			Keyword("line"):     x.Line,
+		}
+	case error:
+		return x.Error()
+	default:
+		if b.stringify {
+			return fmt.Sprintf("%+v", x)
+		}
+		return x
+	}
+}
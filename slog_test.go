@@ -0,0 +1,188 @@
+// Copyright 2016 Russ Olsen. All Rights Reserved.
+//
+// This code is a Go port of the Java version created and maintained by Cognitect, therefore:
+//
+// Copyright 2014 Cognitect. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS-IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transit
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/pborman/uuid"
+	"github.com/shopspring/decimal"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/slogtest"
+	"time"
+)
+
+// stringKeys turns the keyword keys of decoded log records into
+// strings, which is what slogtest expects.
+func stringKeys(x interface{}) interface{} {
+	m, ok := x.(map[interface{}]interface{})
+	if !ok {
+		return x
+	}
+	result := make(map[string]any, len(m))
+	for k, v := range m {
+		result[string(k.(Keyword))] = stringKeys(v)
+	}
+	return result
+}
+
+func decodeLogLines(t *testing.T, buf *bytes.Buffer) []map[interface{}]interface{} {
+	var records []map[interface{}]interface{}
+	for x, err := range NewDecoder(buf).All() {
+		if err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, x.(map[interface{}]interface{}))
+	}
+	return records
+}
+
+func TestSlogHandler(t *testing.T) {
+	var buf bytes.Buffer
+	slogtest.Run(t, func(t *testing.T) slog.Handler {
+		buf.Reset()
+		return NewSlogHandler(&buf, nil)
+	}, func(t *testing.T) map[string]any {
+		records := decodeLogLines(t, &buf)
+		if len(records) != 1 {
+			t.Fatalf("Expected one record, got %d", len(records))
+		}
+		return stringKeys(records[0]).(map[string]any)
+	})
+}
+
+type logUser struct {
+	name string
+}
+
+func (u logUser) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("name", u.name), slog.Any("role", Keyword("admin")))
+}
+
+func TestSlogHandlerTypes(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewSlogHandler(&buf, &slog.HandlerOptions{AddSource: true, Level: slog.LevelDebug}))
+
+	id := uuid.Parse("b51241e0-c115-11e5-a837-0800200c9a66")
+	when := time.Date(2024, 2, 29, 12, 0, 0, 0, time.UTC)
+	logger.With("request", id).WithGroup("order").Debug("placed",
+		"total", decimal.RequireFromString("12.50"),
+		"placed", when,
+		"took", 1500*time.Millisecond,
+		"user", logUser{"alice"},
+		"err", errors.New("retried"),
+		slog.Group("empty"))
+
+	records := decodeLogLines(t, &buf)
+	if len(records) != 1 {
+		t.Fatalf("Expected one record, got %d", len(records))
+	}
+	r := records[0]
+
+	if r[Keyword("level")] != Keyword("debug") || r[Keyword("msg")] != "placed" {
+		t.Errorf("Expected a :debug placed record, got %v", r)
+	}
+	if _, ok := r[Keyword("time")].(time.Time); !ok {
+		t.Errorf("Expected a time, got %v", r[Keyword("time")])
+	}
+	if !reflect.DeepEqual(r[Keyword("request")], id) {
+		t.Errorf("Expected the request UUID, got %v", r[Keyword("request")])
+	}
+	if source, _ := Get[map[interface{}]interface{}](r, Keyword("source")); !strings.HasSuffix(source[Keyword("file")].(string), "slog_test.go") {
+		t.Errorf("Expected the source to be this file, got %v", source)
+	}
+
+	order := r[Keyword("order")].(map[interface{}]interface{})
+	expected := map[interface{}]interface{}{
+		Keyword("placed"): when,
+		Keyword("took"):   int64(1500 * time.Millisecond),
+		Keyword("user"): map[interface{}]interface{}{
+			Keyword("name"): "alice",
+			Keyword("role"): Keyword("admin"),
+		},
+		Keyword("err"): "retried",
+	}
+	if total, _ := order[Keyword("total")].(decimal.Decimal); total.String() != "12.5" {
+		t.Errorf("Expected a decimal total, got %v", order[Keyword("total")])
+	}
+	delete(order, Keyword("total"))
+	if !reflect.DeepEqual(order, expected) {
+		t.Errorf("Expected %v, got %v", expected, order)
+	}
+}
+
+func TestSlogHandlerOptions(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewSlogHandler(&buf, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			switch {
+			case a.Key == slog.TimeKey && len(groups) == 0:
+				return slog.Attr{}
+			case a.Key == "password":
+				return slog.String("password", "*****")
+			}
+			return a
+		},
+	}))
+
+	logger.Debug("hidden")
+	logger.Info("login", slog.Group("user", "name", "alice", "password", "hunter2"), "conn", make(chan int))
+
+	records := decodeLogLines(t, &buf)
+	if len(records) != 1 {
+		t.Fatalf("Expected one record, got %d", len(records))
+	}
+	r := records[0]
+
+	if _, ok := r[Keyword("time")]; ok {
+		t.Errorf("Expected the time to be removed, got %v", r)
+	}
+	user := r[Keyword("user")].(map[interface{}]interface{})
+	if user[Keyword("password")] != "*****" {
+		t.Errorf("Expected the password to be replaced, got %v", user)
+	}
+
+	// The channel cannot be encoded, so it is written as a string
+	// rather than losing the record.
+	if conn, ok := r[Keyword("conn")].(string); !ok || !strings.HasPrefix(conn, "0x") {
+		t.Errorf("Expected the channel as a string, got %v", r[Keyword("conn")])
+	}
+}
+
+func TestSlogHandlerWriteError(t *testing.T) {
+	w := &failingWriter{limit: 0}
+	h := NewSlogHandler(w, nil)
+
+	for _, args := range [][]any{{"n", 1}, {"conn", make(chan int)}} {
+		r := slog.NewRecord(time.Now(), slog.LevelInfo, "failing", 0)
+		r.Add(args...)
+
+		w.failed = false
+		if err := h.Handle(context.Background(), r); err != errDiskFull {
+			t.Errorf("Expected the writer's error for %v, got %v", args, err)
+		}
+		if w.writesAfterEr != 0 {
+			t.Errorf("Expected a single write for %v, got %v more", args, w.writesAfterEr)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright 2016 Russ Olsen. All Rights Reserved.
+//
+// This code is a Go port of the Java version created and maintained by Cognitect, therefore:
+//
+// Copyright 2014 Cognitect. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS-IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transit
+
+import (
+	"database/sql/driver"
+	"fmt"
+)
+
+// Value holds any transit value so that it can be stored in a
+// database column. It implements sql.Scanner and driver.Valuer,
+// storing the value as transit+json text in a text, JSON or binary
+// column, so that keywords, sets, UUIDs and the like come back as
+// themselves. A nil V is stored as NULL. There is no msgpack support,
+// so columns holding transit+msgpack cannot be read.
+type Value struct {
+	V interface{}
+}
+
+// Value encodes v for the database.
+func (v Value) Value() (driver.Value, error) {
+	return columnValue(v.V)
+}
+
+// Scan decodes a column read from the database. NULL scans as nil.
+func (v *Value) Scan(src interface{}) error {
+	x, err := scanColumn(src)
+	if err != nil {
+		return err
+	}
+	v.V = x
+	return nil
+}
+
+// Column holds a value of type T for storing in a database column, as
+// Value does. Structs are stored with struct mapping and read back
+// with DecodeInto, so a struct's fields can be of any type transit
+// knows. NULL scans as the zero value of T, and the zero value of a
+// T that can be nil is stored as NULL.
+type Column[T any] struct {
+	V T
+}
+
+// Value encodes c for the database.
+func (c Column[T]) Value() (driver.Value, error) {
+	return columnValue(c.V)
+}
+
+// Scan decodes a column read from the database.
+func (c *Column[T]) Scan(src interface{}) error {
+	x, err := scanColumn(src)
+	if err != nil {
+		return err
+	}
+	var v T
+	if err := assignTo(&v, x); err != nil {
+		return err
+	}
+	c.V = v
+	return nil
+}
+
+// columnValue returns x as the transit+json text of a column.
+func columnValue(x interface{}) (driver.Value, error) {
+	b, err := encodeMapped(x)
+	if err != nil || b == nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// scanColumn decodes the transit+json in a column. Drivers hand over
+// text as either a string or a []byte.
+func scanColumn(src interface{}) (interface{}, error) {
+	switch s := src.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return DecodeFromString(s)
+	case []byte:
+		return DecodeBytes(s)
+	}
+	msg := fmt.Sprintf("Cannot scan %T as transit", src)
+	return nil, &TransitError{Kind: KindType, Message: msg, Source: src}
+}
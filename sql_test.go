@@ -0,0 +1,228 @@
+// Copyright 2016 Russ Olsen. All Rights Reserved.
+//
+// This code is a Go port of the Java version created and maintained by Cognitect, therefore:
+//
+// Copyright 2014 Cognitect. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS-IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transit
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"github.com/pborman/uuid"
+	"github.com/shopspring/decimal"
+	"io"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// fakeDriver keeps two column tables in memory, one per data source
+// name. Every Exec adds its arguments as a row and every Query returns
+// all of the rows. Strings come back as []byte, as they do from many
+// real drivers.
+type fakeDriver struct {
+	mu     sync.Mutex
+	tables map[string][][]driver.Value
+}
+
+func init() {
+	sql.Register("transit-fake", &fakeDriver{tables: make(map[string][][]driver.Value)})
+}
+
+func (d *fakeDriver) Open(name string) (driver.Conn, error) {
+	return fakeConn{d, name}, nil
+}
+
+type fakeConn struct {
+	d     *fakeDriver
+	table string
+}
+
+func (c fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return fakeStmt(c), nil
+}
+
+func (c fakeConn) Close() error {
+	return nil
+}
+
+func (c fakeConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("no transactions")
+}
+
+type fakeStmt fakeConn
+
+func (s fakeStmt) Close() error {
+	return nil
+}
+
+func (s fakeStmt) NumInput() int {
+	return -1
+}
+
+func (s fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	s.d.tables[s.table] = append(s.d.tables[s.table], append([]driver.Value(nil), args...))
+	return driver.RowsAffected(1), nil
+}
+
+func (s fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	return &fakeRows{rows: s.d.tables[s.table]}, nil
+}
+
+type fakeRows struct {
+	rows [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string {
+	return []string{"name", "data"}
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	for i, v := range r.rows[0] {
+		if s, ok := v.(string); ok {
+			v = []byte(s)
+		}
+		dest[i] = v
+	}
+	r.rows = r.rows[1:]
+	return nil
+}
+
+func openFakeDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("transit-fake", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestSQLValue(t *testing.T) {
+	db := openFakeDB(t)
+
+	id := uuid.Parse("b51241e0-c115-11e5-a837-0800200c9a66")
+	values := map[string]interface{}{
+		"map": map[interface{}]interface{}{
+			Keyword("id"):   id,
+			Keyword("tags"): MakeSet(Keyword("a"), Symbol("b")),
+		},
+		"string": "~not a tag",
+		"null":   nil,
+	}
+	for name, x := range values {
+		if _, err := db.Exec("INSERT", name, Value{x}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rows, err := db.Query("SELECT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	n := 0
+	for rows.Next() {
+		var name string
+		var v Value
+		if err := rows.Scan(&name, &v); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(v.V, values[name]) {
+			t.Errorf("%s: expected %v, got %v", name, values[name], v.V)
+		}
+		n++
+	}
+	if n != len(values) {
+		t.Errorf("Expected %d rows, got %d", len(values), n)
+	}
+}
+
+type sqlAccount struct {
+	ID      uuid.UUID       `transit:"id"`
+	Roles   []Keyword       `transit:"roles"`
+	Groups  *Set            `transit:"groups"`
+	Balance decimal.Decimal `transit:"balance"`
+}
+
+func TestSQLColumn(t *testing.T) {
+	db := openFakeDB(t)
+
+	account := sqlAccount{
+		ID:      uuid.Parse("b51241e0-c115-11e5-a837-0800200c9a66"),
+		Roles:   []Keyword{"admin"},
+		Groups:  MakeSet("staff"),
+		Balance: decimal.RequireFromString("99.95"),
+	}
+	if _, err := db.Exec("INSERT", "account", Column[sqlAccount]{account}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("INSERT", "none", Column[*sqlAccount]{}); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := db.Query("SELECT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var name string
+	var c Column[sqlAccount]
+	rows.Next()
+	if err := rows.Scan(&name, &c); err != nil {
+		t.Fatal(err)
+	}
+	if !c.V.Balance.Equal(account.Balance) {
+		t.Errorf("Expected a balance of %v, got %v", account.Balance, c.V.Balance)
+	}
+	c.V.Balance = account.Balance
+	if !reflect.DeepEqual(c.V, account) {
+		t.Errorf("Expected %+v, got %+v", account, c.V)
+	}
+
+	var raw sql.NullString
+	var none Column[*sqlAccount]
+	rows.Next()
+	if err := rows.Scan(&name, &raw); err != nil || raw.Valid {
+		t.Errorf("Expected a nil pointer to be stored as NULL, got %v, %v", raw, err)
+	}
+	none.V = &account
+	if err := none.Scan(nil); err != nil || none.V != nil {
+		t.Errorf("Expected NULL to scan as nil, got %v, %v", none.V, err)
+	}
+}
+
+func TestSQLScanErrors(t *testing.T) {
+	var v Value
+	VerifyError(t, v.Scan(42), KindType, "[]")
+	VerifyError(t, v.Scan("[1,"), KindSyntax, "[1]")
+
+	var c Column[map[Keyword]int]
+	VerifyError(t, c.Scan(`["^ ","~:a","b"]`), KindType, "[:a]")
+}
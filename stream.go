@@ -0,0 +1,198 @@
+// Copyright 2016 Russ Olsen. All Rights Reserved.
+//
+// This code is a Go port of the Java version created and maintained by Cognitect, therefore:
+//
+// Copyright 2014 Cognitect. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS-IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transit
+
+import (
+	"context"
+	"io"
+	"iter"
+	"time"
+)
+
+// Result is one value sent by Stream, or the error reading it.
+type Result struct {
+	Value interface{}
+	Err   error
+}
+
+// readDeadliner is implemented by readers, such as net.Conn, whose
+// blocked reads can be interrupted with a deadline.
+type readDeadliner interface {
+	SetReadDeadline(t time.Time) error
+}
+
+// aLongTimeAgo is a deadline that has certainly passed.
+var aLongTimeAgo = time.Unix(1, 0)
+
+// SetContinueOnError controls what All and AllAs do after a bad value.
+// By default they stop at the first error. With continueOnError set
+// they skip the rest of the line the bad value failed on and carry
+// on, which suits newline delimited transit, where every value sits on
+// a line of its own. A value that was cut short, and so failed on the
+// next line, costs nothing more.
+// Decoding still stops if the underlying reader fails or the
+// decoder's MaxBytes limit is reached. Decoders created with
+// NewJsonDecoder cannot skip and always stop.
+func (d *Decoder) SetContinueOnError(continueOnError bool) {
+	d.options.continueOnError = continueOnError
+}
+
+// Offset returns the stream offset just past the last value read.
+func (d Decoder) Offset() int64 {
+	if d.jsd != nil {
+		return d.jsd.InputOffset()
+	}
+	return d.scan.Offset()
+}
+
+// All returns an iterator over the values in the stream. The
+// iteration ends at the end of the input, which is not an error.
+// Errors are *TransitErrors holding the offset at which decoding
+// stopped, unless the underlying reader failed, in which case they
+// are the reader's errors.
+func (d Decoder) All() iter.Seq2[interface{}, error] {
+	return func(yield func(interface{}, error) bool) {
+		for {
+			value, err := d.Decode()
+			if err == io.EOF {
+				return
+			}
+			if !yield(value, err) {
+				return
+			}
+			if err != nil && !d.skipBadValue() {
+				return
+			}
+		}
+	}
+}
+
+// AllAs is All for streams of values of type T. A value that is not a
+// T is reported as an error of kind KindType. Since the value itself
+// was well formed, continuing after it does not skip anything.
+func AllAs[T any](d *Decoder) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for value, err := range d.All() {
+			var t T
+			if err == nil {
+				t, err = as[T](value)
+				if err != nil {
+					err = err.(*TransitError).at(d.Offset())
+					if !yield(t, err) || !d.options.continueOnError {
+						return
+					}
+					continue
+				}
+			}
+			if !yield(t, err) {
+				return
+			}
+		}
+	}
+}
+
+// skipBadValue gets the decoder past a value that could not be
+// decoded, if it has been told to continue on errors. It reports
+// whether there is any point in going on.
+func (d Decoder) skipBadValue() bool {
+	if !d.options.continueOnError || d.jsd != nil {
+		return false
+	}
+	if d.scan.err != nil && d.scan.err != io.EOF {
+		return false
+	}
+	d.scan.skipLine()
+	return true
+}
+
+// DecodeContext is Decode, but gives up as soon as ctx is done and
+// returns ctx.Err(). If the decoder reads from a net.Conn, or anything
+// else with a SetReadDeadline method, a blocked read is interrupted by
+// setting a deadline in the past. Otherwise the read carries on in the
+// background until it returns. Either way, once DecodeContext has been
+// cancelled the decoder is somewhere in the middle of a value and
+// should not be used again.
+func (d Decoder) DecodeContext(ctx context.Context) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if ctx.Done() == nil || (d.scan != nil && d.scan.r == nil) {
+		return d.Decode()
+	}
+
+	if d.scan != nil {
+		if rd, ok := d.scan.r.(readDeadliner); ok {
+			stop := context.AfterFunc(ctx, func() {
+				rd.SetReadDeadline(aLongTimeAgo)
+			})
+			value, err := d.Decode()
+			if !stop() {
+				return nil, ctx.Err()
+			}
+			return value, err
+		}
+	}
+
+	done := make(chan Result, 1)
+	go func() {
+		value, err := d.Decode()
+		done <- Result{value, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.Value, r.Err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Stream decodes values in the background and sends them down the
+// returned channel until the end of the input, an error or ctx being
+// done, at which point the channel is closed. A cancelled stream
+// sends nothing more: check ctx.Err() to tell cancellation apart from
+// the end of the input. The channel is unbuffered, but the decoder
+// reads one value ahead: while the consumer works on a value, the next
+// one is decoded and waits to be sent. It reads no further than that,
+// so a slow consumer slows down the producer rather than piling up
+// values.
+// With SetContinueOnError, errors are sent and the stream carries on.
+func (d Decoder) Stream(ctx context.Context) <-chan Result {
+	results := make(chan Result)
+
+	go func() {
+		defer close(results)
+		for {
+			value, err := d.DecodeContext(ctx)
+			if err == io.EOF || ctx.Err() != nil {
+				return
+			}
+			select {
+			case results <- Result{value, err}:
+			case <-ctx.Done():
+				return
+			}
+			if err != nil && !d.skipBadValue() {
+				return
+			}
+		}
+	}()
+
+	return results
+}
@@ -0,0 +1,205 @@
+// Copyright 2016 Russ Olsen. All Rights Reserved.
+//
+// This code is a Go port of the Java version created and maintained by Cognitect, therefore:
+//
+// Copyright 2014 Cognitect. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS-IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transit
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// silentReader blocks until it is closed, like a peer that never
+// sends anything, and has no way to interrupt a read.
+type silentReader chan struct{}
+
+func (sr silentReader) Read(p []byte) (int, error) {
+	<-sr
+	return 0, io.EOF
+}
+
+func TestDecodeContextCancel(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := NewDecoder(client).DecodeContext(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the decode to be cancelled, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Cancellation took %v", elapsed)
+	}
+}
+
+func TestDecodeContextDeadline(t *testing.T) {
+	sr := make(silentReader)
+	defer close(sr)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if _, err := NewDecoder(sr).DecodeContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the deadline to pass, got %v", err)
+	}
+}
+
+func TestDecodeContextSlowPeer(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+
+	go func() {
+		for _, part := range []string{`["^ ","~:name",`, `"Alice"`, `]`} {
+			time.Sleep(5 * time.Millisecond)
+			server.Write([]byte(part))
+		}
+		server.Close()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	d := NewDecoder(client)
+	value, err := d.DecodeContext(ctx)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if name := value.(map[interface{}]interface{})[Keyword("name")]; name != "Alice" {
+		t.Errorf("Expected Alice, got %v", value)
+	}
+	if _, err := d.DecodeContext(ctx); err != io.EOF {
+		t.Errorf("Expected the end of the input, got %v", err)
+	}
+}
+
+func TestStream(t *testing.T) {
+	d := NewDecoder(strings.NewReader("1\n2\n[3,,\n4\n"))
+	d.SetContinueOnError(true)
+
+	var values []interface{}
+	var errs []error
+	for r := range d.Stream(context.Background()) {
+		if r.Err != nil {
+			errs = append(errs, r.Err)
+		} else {
+			values = append(values, r.Value)
+		}
+	}
+
+	if len(values) != 3 || values[2] != int64(4) || len(errs) != 1 {
+		t.Errorf("Expected 1, 2 and 4 and an error, got %v %v", values, errs)
+	}
+}
+
+func TestStreamBackpressure(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+
+	var written atomic.Int32
+	go func() {
+		defer server.Close()
+		for i := 0; i < 100; i++ {
+			if _, err := server.Write([]byte("[1,2,3]\n")); err != nil {
+				return
+			}
+			written.Add(1)
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	results := NewDecoder(client).Stream(ctx)
+
+	if r := <-results; r.Err != nil {
+		t.Fatalf("Unexpected error: %v", r.Err)
+	}
+
+	// Nobody is reading, so the peer should soon be stuck.
+	time.Sleep(50 * time.Millisecond)
+	if n := written.Load(); n > 5 {
+		t.Errorf("Expected the stream to stop reading, but the peer wrote %v values", n)
+	}
+
+	cancel()
+	for r := range results {
+		t.Errorf("Expected nothing after cancelling, got %v", r)
+	}
+}
+
+func TestStreamReadsOneAhead(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+
+	var written atomic.Int32
+	go func() {
+		defer server.Close()
+		for i := 0; i < 5; i++ {
+			if _, err := server.Write([]byte("[1,2,3]\n")); err != nil {
+				return
+			}
+			written.Add(1)
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	results := NewDecoder(client).Stream(ctx)
+
+	// Nothing has been asked for, so only the value waiting to be sent
+	// has been read.
+	time.Sleep(50 * time.Millisecond)
+	if n := written.Load(); n != 1 {
+		t.Fatalf("Expected one value read ahead, but the peer wrote %v", n)
+	}
+
+	for i := int32(1); i <= 3; i++ {
+		if r := <-results; r.Err != nil {
+			t.Fatalf("Unexpected error: %v", r.Err)
+		}
+		time.Sleep(50 * time.Millisecond)
+		if n := written.Load(); n != i+1 {
+			t.Fatalf("Expected %v values read after receiving %v, but the peer wrote %v", i+1, i, n)
+		}
+	}
+}
+
+func TestStreamCancelSilentPeer(t *testing.T) {
+	sr := make(silentReader)
+	defer close(sr)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	results := NewDecoder(sr).Stream(ctx)
+	cancel()
+
+	select {
+	case _, ok := <-results:
+		if ok {
+			t.Error("Expected the stream to close")
+		}
+	case <-time.After(time.Second):
+		t.Error("Cancelling the stream took too long")
+	}
+}
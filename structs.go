@@ -0,0 +1,371 @@
+// Copyright 2016 Russ Olsen. All Rights Reserved.
+//
+// This code is a Go port of the Java version created and maintained by Cognitect, therefore:
+//
+// Copyright 2014 Cognitect. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS-IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transit
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// structField describes one field of a struct as it appears in a
+// transit map.
+type structField struct {
+	name      string
+	index     []int
+	omitEmpty bool
+}
+
+// structInfo is the list of fields for a struct type, along with a
+// way to find them by name.
+type structInfo struct {
+	fields []structField
+	byName map[string]*structField
+}
+
+var structInfos sync.Map // map[reflect.Type]*structInfo
+
+// structInfoFor returns the fields of struct type t. Only exported
+// fields are included. A field's name in the map is the Go name
+// unless the field has a transit tag, which works like the json tag:
+// `transit:"name,omitempty"`, or `transit:"-"` to leave it out. The
+// fields of embedded structs are promoted, as they are in Go.
+func structInfoFor(t reflect.Type) *structInfo {
+	if info, ok := structInfos.Load(t); ok {
+		return info.(*structInfo)
+	}
+
+	info := &structInfo{byName: make(map[string]*structField)}
+	for _, f := range reflect.VisibleFields(t) {
+		tag, hasTag := f.Tag.Lookup("transit")
+		if !f.IsExported() || tag == "-" {
+			continue
+		}
+		if f.Anonymous && !hasTag && indirect(f.Type).Kind() == reflect.Struct {
+			continue
+		}
+
+		name, options, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = f.Name
+		}
+		info.fields = append(info.fields, structField{name: name, index: f.Index, omitEmpty: options == "omitempty"})
+	}
+	for i := range info.fields {
+		info.byName[info.fields[i].name] = &info.fields[i]
+	}
+
+	actual, _ := structInfos.LoadOrStore(t, info)
+	return actual.(*structInfo)
+}
+
+// indirect returns the type that t points to, or t itself.
+func indirect(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Ptr {
+		return t.Elem()
+	}
+	return t
+}
+
+// StructEncoder writes a struct as a map from keywords to the
+// values of its fields. See SetStructMapping.
+type StructEncoder struct{}
+
+func NewStructEncoder() *StructEncoder {
+	return &StructEncoder{}
+}
+
+func (se StructEncoder) IsStringable(v reflect.Value) bool {
+	return false
+}
+
+func (se StructEncoder) Encode(e Encoder, v reflect.Value, asKey bool) error {
+	if err := e.emitStartMap(e.verbose); err != nil {
+		return err
+	}
+
+	i := 0
+	for _, f := range structInfoFor(v.Type()).fields {
+		field, err := v.FieldByIndexErr(f.index)
+		if err != nil || (f.omitEmpty && field.IsZero()) {
+			// The field is inside a nil embedded pointer, or empty.
+			continue
+		}
+
+		if err := e.emitBeforeKey(e.verbose, i); err != nil {
+			return err
+		}
+		if err := e.EncodeInterface(Keyword(f.name), true); err != nil {
+			return err
+		}
+		if err := e.emitBeforeValue(e.verbose); err != nil {
+			return err
+		}
+		if err := e.encodeElement(field, false); err != nil {
+			return withPath(err, Keyword(f.name))
+		}
+		i++
+	}
+
+	return e.emitEndMap(e.verbose)
+}
+
+// SetStructMapping controls whether the encoder writes structs that
+// have no handler of their own as maps, with a keyword key for each
+// field. It is off by default, which makes such structs an error of
+// kind KindUnknownType. Decoder.DecodeInto reads the maps back.
+func (e *Encoder) SetStructMapping(enabled bool) {
+	if enabled {
+		e.addHandler(reflect.Struct, NewStructEncoder())
+	} else {
+		delete(e.valueEncoders, reflect.Struct)
+	}
+	e.plans.clear()
+}
+
+// encodeMapped returns x as transit+json, written with struct mapping
+// on, or nil if x is nil.
+func encodeMapped(x interface{}) ([]byte, error) {
+	if x == nil {
+		return nil, nil
+	}
+	if v := reflect.ValueOf(x); nilable(v.Type()) && v.IsNil() {
+		return nil, nil
+	}
+
+	var buf bytes.Buffer
+	e := NewEncoder(&buf, false)
+	e.SetStructMapping(true)
+	if err := e.Encode(x); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// DecodeInto decodes the next value from d and stores it in the
+// value that ptr points to, converting it as it goes: maps fill in
+// structs and typed maps, arrays and sets fill in slices, and
+// numbers fill in any numeric type they fit in. Map keys match
+// struct fields by name, as keywords or strings, and keys with no
+// matching field are ignored. A value that cannot be stored is an
+// error of kind KindType.
+func (d Decoder) DecodeInto(ptr interface{}) error {
+	x, err := d.Decode()
+	if err != nil {
+		return err
+	}
+	return assignTo(ptr, x)
+}
+
+// assignTo stores x in the value that ptr points to.
+func assignTo(ptr interface{}, x interface{}) error {
+	v := reflect.ValueOf(ptr)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return &TransitError{Kind: KindType, Message: fmt.Sprintf("Cannot decode into %T, need a non-nil pointer", ptr), Source: ptr}
+	}
+	return assign(v.Elem(), x)
+}
+
+// assign stores x in dst, converting it to dst's type.
+func assign(dst reflect.Value, x interface{}) error {
+	if x == nil {
+		dst.SetZero()
+		return nil
+	}
+
+	v := reflect.ValueOf(x)
+	if v.Type().AssignableTo(dst.Type()) {
+		dst.Set(v)
+		return nil
+	}
+	if v.Kind() == reflect.Ptr && !v.IsNil() && v.Elem().Type().AssignableTo(dst.Type()) {
+		// Say a *big.Int going into a big.Int.
+		dst.Set(v.Elem())
+		return nil
+	}
+
+	switch dst.Kind() {
+	case reflect.Ptr:
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		return assign(dst.Elem(), x)
+
+	case reflect.Struct:
+		if m, ok := x.(map[interface{}]interface{}); ok {
+			return assignStruct(dst, m)
+		}
+
+	case reflect.Map:
+		switch m := x.(type) {
+		case map[interface{}]interface{}:
+			dst.Set(reflect.MakeMapWithSize(dst.Type(), len(m)))
+			for key, value := range m {
+				if err := assignEntry(dst, key, value); err != nil {
+					return err
+				}
+			}
+			return nil
+		case *CMap:
+			dst.Set(reflect.MakeMapWithSize(dst.Type(), len(m.Entries)))
+			for _, entry := range m.Entries {
+				if err := assignEntry(dst, entry.Key, entry.Value); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+	case reflect.Slice:
+		if elements, ok := elementsOf(x); ok {
+			dst.Set(reflect.MakeSlice(dst.Type(), len(elements), len(elements)))
+			return assignElements(dst, elements)
+		}
+
+	case reflect.Array:
+		if elements, ok := elementsOf(x); ok && len(elements) == dst.Len() {
+			return assignElements(dst, elements)
+		}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if i, ok := x.(int64); ok && !dst.OverflowInt(i) {
+			dst.SetInt(i)
+			return nil
+		}
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if i, ok := x.(int64); ok && i >= 0 && !dst.OverflowUint(uint64(i)) {
+			dst.SetUint(uint64(i))
+			return nil
+		}
+
+	case reflect.Float32, reflect.Float64:
+		switch n := x.(type) {
+		case float64:
+			dst.SetFloat(n)
+			return nil
+		case int64:
+			dst.SetFloat(float64(n))
+			return nil
+		}
+
+	case reflect.String:
+		// Strings, keywords and symbols all fit in any string type.
+		if v.Kind() == reflect.String {
+			dst.SetString(v.String())
+			return nil
+		}
+
+	case reflect.Bool:
+		if v.Kind() == reflect.Bool {
+			dst.SetBool(v.Bool())
+			return nil
+		}
+	}
+
+	msg := fmt.Sprintf("Cannot decode %v into %v", v.Type(), dst.Type())
+	return &TransitError{Kind: KindType, Message: msg, Source: x}
+}
+
+// assignStruct fills in the fields of dst from the entries of m.
+func assignStruct(dst reflect.Value, m map[interface{}]interface{}) error {
+	info := structInfoFor(dst.Type())
+	for key, value := range m {
+		var name string
+		switch k := key.(type) {
+		case Keyword:
+			name = string(k)
+		case string:
+			name = k
+		default:
+			continue
+		}
+
+		f := info.byName[name]
+		if f == nil {
+			continue
+		}
+
+		field := fieldForSet(dst, f.index)
+		if !field.IsValid() {
+			continue
+		}
+		if err := assign(field, value); err != nil {
+			return withPath(err, key)
+		}
+	}
+	return nil
+}
+
+// fieldForSet returns the field of dst at index, allocating any nil
+// embedded pointers on the way. It returns the zero Value if one of
+// those pointers cannot be set.
+func fieldForSet(dst reflect.Value, index []int) reflect.Value {
+	v := dst
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// assignEntry converts key and value and stores them in map dst.
+func assignEntry(dst reflect.Value, key, value interface{}) error {
+	k := reflect.New(dst.Type().Key()).Elem()
+	if err := assign(k, key); err != nil {
+		return withPath(err, key)
+	}
+	e := reflect.New(dst.Type().Elem()).Elem()
+	if err := assign(e, value); err != nil {
+		return withPath(err, key)
+	}
+	dst.SetMapIndex(k, e)
+	return nil
+}
+
+// assignElements fills in slice or array dst from elements.
+func assignElements(dst reflect.Value, elements []interface{}) error {
+	for i, element := range elements {
+		if err := assign(dst.Index(i), element); err != nil {
+			return withPath(err, i)
+		}
+	}
+	return nil
+}
+
+// elementsOf returns the elements of a decoded array or set.
+func elementsOf(x interface{}) ([]interface{}, bool) {
+	switch c := x.(type) {
+	case []interface{}:
+		return c, true
+	case *Set:
+		return c.Contents, true
+	}
+	return nil, false
+}
@@ -0,0 +1,205 @@
+// Copyright 2016 Russ Olsen. All Rights Reserved.
+//
+// This code is a Go port of the Java version created and maintained by Cognitect, therefore:
+//
+// Copyright 2014 Cognitect. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS-IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transit
+
+import (
+	"bytes"
+	"github.com/shopspring/decimal"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type testAddress struct {
+	City string `transit:"city"`
+}
+
+// Audit is exported so that a nil *Audit embedded in a struct can be
+// filled in when decoding.
+type Audit struct {
+	Version int `transit:"version"`
+}
+
+type testPerson struct {
+	*Audit
+	Name    string          `transit:"name"`
+	Age     int8            `transit:"age,omitempty"`
+	Role    Keyword         `transit:"role"`
+	Tags    *Set            `transit:"tags"`
+	Balance decimal.Decimal `transit:"balance"`
+	Home    *testAddress    `transit:"home"`
+	Scores  map[string]uint `transit:"scores"`
+	Skipped string          `transit:"-"`
+	Active  bool
+	secret  string
+}
+
+func encodeStructs(t *testing.T, x interface{}) string {
+	var buf bytes.Buffer
+	e := NewEncoder(&buf, false)
+	e.SetStructMapping(true)
+	if err := e.Encode(x); err != nil {
+		t.Fatalf("Error encoding %v: %v", x, err)
+	}
+	return buf.String()
+}
+
+func TestWriteStructs(t *testing.T) {
+	p := testPerson{
+		Audit:   &Audit{Version: 3},
+		Name:    "Alice",
+		Role:    Keyword("admin"),
+		Skipped: "skipped",
+		secret:  "secret",
+	}
+
+	x, err := DecodeFromString(encodeStructs(t, p))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[interface{}]interface{}{
+		Keyword("version"): int64(3),
+		Keyword("name"):    "Alice",
+		Keyword("role"):    Keyword("admin"),
+		Keyword("tags"):    nil,
+		Keyword("home"):    nil,
+		Keyword("scores"):  nil,
+		Keyword("Active"):  false,
+	}
+	m := x.(map[interface{}]interface{})
+	if balance, _ := m[Keyword("balance")].(decimal.Decimal); !balance.IsZero() {
+		t.Errorf("Expected a zero balance, got %v", m[Keyword("balance")])
+	}
+	delete(m, Keyword("balance"))
+	if !reflect.DeepEqual(m, expected) {
+		t.Errorf("Expected %v, got %v", expected, m)
+	}
+
+	// Fields in a nil embedded pointer are left out.
+	p.Audit = nil
+	x, _ = DecodeFromString(encodeStructs(t, p))
+	if _, ok := x.(map[interface{}]interface{})[Keyword("version")]; ok {
+		t.Errorf("Expected no version in %v", x)
+	}
+}
+
+func TestWriteStructsOff(t *testing.T) {
+	_, err := EncodeToString(testAddress{City: "Paris"}, false)
+	VerifyError(t, err, KindUnknownType, "[]")
+
+	var buf bytes.Buffer
+	e := NewEncoder(&buf, false)
+	e.SetStructMapping(true)
+	e.SetStructMapping(false)
+	VerifyError(t, e.Encode(testAddress{}), KindUnknownType, "[]")
+}
+
+func TestDecodeIntoStructs(t *testing.T) {
+	p := testPerson{
+		Audit:   &Audit{Version: 3},
+		Name:    "Alice",
+		Age:     42,
+		Role:    Keyword("admin"),
+		Tags:    MakeSet(Keyword("a"), "b"),
+		Balance: decimal.RequireFromString("1234.5678"),
+		Home:    &testAddress{City: "Paris"},
+		Scores:  map[string]uint{"chess": 1800},
+		Active:  true,
+	}
+
+	for _, verbose := range []bool{false, true} {
+		var buf bytes.Buffer
+		e := NewEncoder(&buf, verbose)
+		e.SetStructMapping(true)
+		if err := e.Encode(p); err != nil {
+			t.Fatal(err)
+		}
+
+		var q testPerson
+		if err := NewDecoder(&buf).DecodeInto(&q); err != nil {
+			t.Fatal(err)
+		}
+		if !q.Balance.Equal(p.Balance) {
+			t.Errorf("Expected balance %v, got %v", p.Balance, q.Balance)
+		}
+		q.Balance = p.Balance
+		if !reflect.DeepEqual(p, q) {
+			t.Errorf("Expected %+v, got %+v", p, q)
+		}
+	}
+}
+
+func TestDecodeIntoConversions(t *testing.T) {
+	var ints []int16
+	err := NewDecoder(strings.NewReader(`[1,2,3]`)).DecodeInto(&ints)
+	if err != nil || !reflect.DeepEqual(ints, []int16{1, 2, 3}) {
+		t.Errorf("Expected [1 2 3], got %v, %v", ints, err)
+	}
+
+	var fromSet [2]string
+	err = NewDecoder(strings.NewReader(`["~#set",["a","~:b"]]`)).DecodeInto(&fromSet)
+	if err != nil || !reflect.DeepEqual(fromSet, [2]string{"a", "b"}) {
+		t.Errorf("Expected [a b], got %v, %v", fromSet, err)
+	}
+
+	var byKey map[Keyword]float64
+	err = NewDecoder(strings.NewReader(`["^ ","~:x",1,"~:y",2.5]`)).DecodeInto(&byKey)
+	if err != nil || !reflect.DeepEqual(byKey, map[Keyword]float64{"x": 1, "y": 2.5}) {
+		t.Errorf("Expected map[x:1 y:2.5], got %v, %v", byKey, err)
+	}
+
+	var byInt map[int][]string
+	err = NewDecoder(strings.NewReader(`["~#cmap",[[1],["a"],2,null]]`)).DecodeInto(&byInt)
+	if err != nil || !reflect.DeepEqual(byInt, map[int][]string{2: nil}) {
+		t.Logf("cmap keys that are arrays cannot be ints: %v", err)
+	}
+
+	var anything interface{}
+	err = NewDecoder(strings.NewReader(`["~:a"]`)).DecodeInto(&anything)
+	if err != nil || !reflect.DeepEqual(anything, []interface{}{Keyword("a")}) {
+		t.Errorf("Expected [:a], got %v, %v", anything, err)
+	}
+
+	// Keys without a field are ignored.
+	var address testAddress
+	err = NewDecoder(strings.NewReader(`["^ ","city","Paris","~:zip","75001"]`)).DecodeInto(&address)
+	if err != nil || address.City != "Paris" {
+		t.Errorf("Expected Paris, got %v, %v", address, err)
+	}
+}
+
+func TestDecodeIntoErrors(t *testing.T) {
+	var p testPerson
+	err := NewDecoder(strings.NewReader(`["^ ","~:home",["^ ","~:city",12]]`)).DecodeInto(&p)
+	VerifyError(t, err, KindType, "[:home :city]")
+
+	err = NewDecoder(strings.NewReader(`["^ ","~:age",300]`)).DecodeInto(&p)
+	VerifyError(t, err, KindType, "[:age]")
+
+	err = NewDecoder(strings.NewReader(`["^ ","~:scores",["^ ","chess",-1]]`)).DecodeInto(&p)
+	VerifyError(t, err, KindType, "[:scores chess]")
+
+	var pair [2]int
+	err = NewDecoder(strings.NewReader(`[1,2,3]`)).DecodeInto(&pair)
+	VerifyError(t, err, KindType, "[]")
+
+	err = NewDecoder(strings.NewReader(`1`)).DecodeInto(p)
+	VerifyError(t, err, KindType, "[]")
+}
@@ -0,0 +1,218 @@
+// Copyright 2016 Russ Olsen. All Rights Reserved.
+//
+// This code is a Go port of the Java version created and maintained by Cognitect, therefore:
+//
+// Copyright 2014 Cognitect. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS-IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transit
+
+import (
+	"fmt"
+	"iter"
+	"reflect"
+)
+
+// typeError reports that x is not a T.
+func typeError[T any](x interface{}) *TransitError {
+	got := "nil"
+	if x != nil {
+		got = reflect.TypeOf(x).String()
+	}
+	msg := fmt.Sprintf("Expected %v, got %v", reflect.TypeFor[T](), got)
+	return &TransitError{Kind: KindType, Message: msg, Source: x}
+}
+
+// as converts x to a T. Transit null converts to the zero value of
+// any type that can be nil. Nothing else is converted, so numbers
+// come out as int64 or float64 and never as, say, an int.
+func as[T any](x interface{}) (T, error) {
+	if t, ok := x.(T); ok {
+		return t, nil
+	}
+	var zero T
+	if x == nil && nilable(reflect.TypeFor[T]()) {
+		return zero, nil
+	}
+	return zero, typeError[T](x)
+}
+
+// DecodeAs decodes the next value from d and returns it as a T. If
+// the value is not a T, the error is a *TransitError of kind KindType.
+func DecodeAs[T any](d *Decoder) (T, error) {
+	x, err := d.Decode()
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return as[T](x)
+}
+
+// Get returns the value of key in m as a T. A missing key reads as
+// null. If the value is not a T, the error is a *TransitError of kind
+// KindType with the key as its Path.
+func Get[T any](m map[interface{}]interface{}, key interface{}) (T, error) {
+	t, err := as[T](m[key])
+	if err != nil {
+		return t, withPath(err, key)
+	}
+	return t, nil
+}
+
+// SetOf is a view of a Set whose elements are all of type T.
+type SetOf[T any] struct {
+	set *Set
+}
+
+// AsSetOf checks that x is a Set, or a pointer to one, whose elements
+// are all Ts and returns a typed view of it.
+func AsSetOf[T any](x interface{}) (SetOf[T], error) {
+	var s *Set
+	switch v := x.(type) {
+	case *Set:
+		s = v
+	case Set:
+		s = &v
+	default:
+		return SetOf[T]{}, typeError[*Set](x)
+	}
+
+	for i, element := range s.Contents {
+		if _, err := as[T](element); err != nil {
+			return SetOf[T]{}, withPath(err, i)
+		}
+	}
+	return SetOf[T]{s}, nil
+}
+
+// Set returns the underlying Set.
+func (s SetOf[T]) Set() *Set {
+	return s.set
+}
+
+// Len returns the number of elements in the set.
+func (s SetOf[T]) Len() int {
+	if s.set == nil {
+		return 0
+	}
+	return len(s.set.Contents)
+}
+
+// At returns the i'th element of the set.
+func (s SetOf[T]) At(i int) T {
+	t, _ := as[T](s.set.Contents[i])
+	return t
+}
+
+// Contains reports whether the set holds value. Elements are compared
+// with reflect.DeepEqual, so composite values can be found too.
+func (s SetOf[T]) Contains(value T) bool {
+	return s.set != nil && s.set.Contains(value, reflect.DeepEqual)
+}
+
+// All returns an iterator over the elements of the set.
+func (s SetOf[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for i := 0; i < s.Len(); i++ {
+			if !yield(s.At(i)) {
+				return
+			}
+		}
+	}
+}
+
+// Slice returns the elements of the set in a new slice.
+func (s SetOf[T]) Slice() []T {
+	result := make([]T, s.Len())
+	for i := range result {
+		result[i] = s.At(i)
+	}
+	return result
+}
+
+// CMapOf is a view of a CMap whose keys are all of type K and whose
+// values are all of type V.
+type CMapOf[K, V any] struct {
+	cmap *CMap
+}
+
+// AsCMapOf checks that x is a CMap, or a pointer to one, whose keys
+// are all Ks and whose values are all Vs and returns a typed view
+// of it.
+func AsCMapOf[K, V any](x interface{}) (CMapOf[K, V], error) {
+	var cm *CMap
+	switch v := x.(type) {
+	case *CMap:
+		cm = v
+	case CMap:
+		cm = &v
+	default:
+		return CMapOf[K, V]{}, typeError[*CMap](x)
+	}
+
+	for i, entry := range cm.Entries {
+		if _, err := as[K](entry.Key); err != nil {
+			return CMapOf[K, V]{}, withPath(err, i)
+		}
+		if _, err := as[V](entry.Value); err != nil {
+			return CMapOf[K, V]{}, withPath(err, entry.Key)
+		}
+	}
+	return CMapOf[K, V]{cm}, nil
+}
+
+// CMap returns the underlying CMap.
+func (cm CMapOf[K, V]) CMap() *CMap {
+	return cm.cmap
+}
+
+// Len returns the number of entries in the map.
+func (cm CMapOf[K, V]) Len() int {
+	if cm.cmap == nil {
+		return 0
+	}
+	return len(cm.cmap.Entries)
+}
+
+// At returns the key and value of the i'th entry in the map.
+func (cm CMapOf[K, V]) At(i int) (K, V) {
+	entry := cm.cmap.Entries[i]
+	k, _ := as[K](entry.Key)
+	v, _ := as[V](entry.Value)
+	return k, v
+}
+
+// Get returns the value for key and whether there was one. Keys are
+// compared with reflect.DeepEqual, since they are usually composite.
+func (cm CMapOf[K, V]) Get(key K) (V, bool) {
+	for i := 0; i < cm.Len(); i++ {
+		if reflect.DeepEqual(cm.cmap.Entries[i].Key, key) {
+			_, v := cm.At(i)
+			return v, true
+		}
+	}
+	var zero V
+	return zero, false
+}
+
+// All returns an iterator over the entries of the map.
+func (cm CMapOf[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for i := 0; i < cm.Len(); i++ {
+			if !yield(cm.At(i)) {
+				return
+			}
+		}
+	}
+}
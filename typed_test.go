@@ -0,0 +1,145 @@
+// Copyright 2016 Russ Olsen. All Rights Reserved.
+//
+// This code is a Go port of the Java version created and maintained by Cognitect, therefore:
+//
+// Copyright 2014 Cognitect. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS-IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transit
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecodeAs(t *testing.T) {
+	d := NewDecoder(strings.NewReader(`"~:a" 42 null "x"`))
+
+	k, err := DecodeAs[Keyword](d)
+	if err != nil || k != Keyword("a") {
+		t.Errorf("Expected :a, got %v, %v", k, err)
+	}
+
+	n, err := DecodeAs[int64](d)
+	if err != nil || n != 42 {
+		t.Errorf("Expected 42, got %v, %v", n, err)
+	}
+
+	m, err := DecodeAs[map[interface{}]interface{}](d)
+	if err != nil || m != nil {
+		t.Errorf("Expected null to decode as a nil map, got %v, %v", m, err)
+	}
+
+	_, err = DecodeAs[int64](d)
+	if !errors.Is(err, KindType) || err.Error() != "Expected int64, got string" {
+		t.Errorf("Expected a type error, got %v", err)
+	}
+
+	if _, err = DecodeAs[int64](d); err == nil || errors.Is(err, KindType) {
+		t.Errorf("Expected the end of the input, got %v", err)
+	}
+}
+
+func TestGet(t *testing.T) {
+	value, err := DecodeFromString(`["^ ","~:name","Alice","~:age",42,"~:tags",["~#set",["~:a"]]]`)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	m := value.(map[interface{}]interface{})
+
+	if name, err := Get[string](m, Keyword("name")); err != nil || name != "Alice" {
+		t.Errorf("Expected Alice, got %v, %v", name, err)
+	}
+
+	if s, err := Get[*Set](m, Keyword("missing")); err != nil || s != nil {
+		t.Errorf("Expected a missing key to read as nil, got %v, %v", s, err)
+	}
+
+	_, err = Get[int](m, Keyword("age"))
+	VerifyError(t, err, KindType, "[:age]")
+
+	_, err = Get[string](m, Keyword("missing"))
+	VerifyError(t, err, KindType, "[:missing]")
+}
+
+func TestSetOf(t *testing.T) {
+	value, err := DecodeFromString(`["~#set",["~:a","~:b",["~:c"]]]`)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	_, err = AsSetOf[Keyword](value)
+	VerifyError(t, err, KindType, "[2]")
+
+	_, err = AsSetOf[Keyword]("not a set")
+	VerifyError(t, err, KindType, "[]")
+
+	s, err := AsSetOf[interface{}](value)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if s.Len() != 3 || !s.Contains([]interface{}{Keyword("c")}) || s.Contains(Keyword("d")) {
+		t.Errorf("Unexpected set contents: %v", s.Set())
+	}
+
+	keywords, err := AsSetOf[Keyword](MakeSet(Keyword("a"), Keyword("b")))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var all []Keyword
+	for k := range keywords.All() {
+		all = append(all, k)
+	}
+	if expected := []Keyword{"a", "b"}; !reflect.DeepEqual(all, expected) || !reflect.DeepEqual(keywords.Slice(), expected) {
+		t.Errorf("Expected %v, got %v and %v", expected, all, keywords.Slice())
+	}
+}
+
+func TestCMapOf(t *testing.T) {
+	value, err := DecodeFromString(`["~#cmap",[[1,2],"a",[3],"b"]]`)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	cm, err := AsCMapOf[[]interface{}, string](value)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if v, ok := cm.Get([]interface{}{int64(3)}); !ok || v != "b" {
+		t.Errorf("Expected b, got %v, %v", v, ok)
+	}
+	if _, ok := cm.Get([]interface{}{int64(4)}); ok {
+		t.Errorf("Expected no value for [4]")
+	}
+
+	n := 0
+	for k, v := range cm.All() {
+		if len(k) == 0 || v == "" {
+			t.Errorf("Unexpected entry %v %v", k, v)
+		}
+		n++
+	}
+	if n != cm.Len() || n != 2 {
+		t.Errorf("Expected 2 entries, got %v", n)
+	}
+
+	_, err = AsCMapOf[[]interface{}, int64](value)
+	VerifyError(t, err, KindType, "[[1 2]]")
+
+	_, err = AsCMapOf[string, string](value)
+	VerifyError(t, err, KindType, "[0]")
+}
@@ -29,16 +29,35 @@ import (
 	"time"
 )
 
+// stringRep returns the rep of a scalar value, which must be a string.
+// Reps given to a handler through a tag come wrapped in a TaggedValue.
+func stringRep(what string, x interface{}) (string, error) {
+	if tv, ok := x.(TaggedValue); ok {
+		x = tv.Value
+	}
+	s, ok := x.(string)
+	if !ok {
+		return "", newSyntaxError(what+" is not a string.", x, nil)
+	}
+	return s, nil
+}
+
 // DecodeKeyword decodes ~: style keywords.
 func DecodeKeyword(d Decoder, x interface{}) (interface{}, error) {
-	s := x.(string)
+	s, err := stringRep("Keyword", x)
+	if err != nil {
+		return nil, err
+	}
 	var result = Keyword(s)
 	return result, nil
 }
 
 // DecodeKeyword decodes ~$ style symbols.
 func DecodeSymbol(d Decoder, x interface{}) (interface{}, error) {
-	s := x.(string)
+	s, err := stringRep("Symbol", x)
+	if err != nil {
+		return nil, err
+	}
 	var result = Symbol(s)
 	return result, nil
 }
@@ -51,16 +70,16 @@ func DecodeIdentity(d Decoder, x interface{}) (interface{}, error) {
 // DecodeCMap decodes maps with composite keys.
 func DecodeCMap(d Decoder, x interface{}) (interface{}, error) {
 
-	tagged := x.(TaggedValue)
+	tagged, _ := x.(TaggedValue)
 
 	if !IsGenericArray(tagged.Value) {
-		return nil, NewTransitError("Cmap contents are not an array.", tagged)
+		return nil, newSyntaxError("Cmap contents are not an array.", tagged, nil)
 	}
 
 	array := tagged.Value.([]interface{})
 
 	if (len(array) % 2) != 0 {
-		return nil, NewTransitError("Cmap contents must contain an even number of elements.", tagged)
+		return nil, newSyntaxError("Cmap contents must contain an even number of elements.", tagged, nil)
 	}
 
 	var result = NewCMap()
@@ -78,9 +97,9 @@ func DecodeCMap(d Decoder, x interface{}) (interface{}, error) {
 
 // DecodeSet decodes a transit set into a transit.Set instance.
 func DecodeSet(d Decoder, x interface{}) (interface{}, error) {
-	tagged := x.(TaggedValue)
+	tagged, _ := x.(TaggedValue)
 	if !IsGenericArray(tagged.Value) {
-		return nil, NewTransitError("Set contents are not an array.", tagged)
+		return nil, newSyntaxError("Set contents are not an array.", tagged, nil)
 	}
 	values := (tagged.Value).([]interface{})
 	result := NewSet(values)
@@ -89,9 +108,9 @@ func DecodeSet(d Decoder, x interface{}) (interface{}, error) {
 
 // DecodeList decodes a transit list into a Go list.
 func DecodeList(d Decoder, x interface{}) (interface{}, error) {
-	tagged := x.(TaggedValue)
+	tagged, _ := x.(TaggedValue)
 	if !IsGenericArray(tagged.Value) {
-		return nil, NewTransitError("List contents are not an array.", tagged)
+		return nil, newSyntaxError("List contents are not an array.", tagged, nil)
 	}
 	values := (tagged.Value).([]interface{})
 	result := list.New()
@@ -103,22 +122,36 @@ func DecodeList(d Decoder, x interface{}) (interface{}, error) {
 
 // DecodeQuote decodes a transit quoted value by simply returning the value.
 func DecodeQuote(d Decoder, x interface{}) (interface{}, error) {
-	tagged := x.(TaggedValue)
-	return tagged.Value, nil
+	if tagged, ok := x.(TaggedValue); ok {
+		return tagged.Value, nil
+	}
+	return x, nil
 }
 
 // DecodeRFC3339 decodes a time value into a Go time instance.
 // TBD not 100% this covers all possible values.
 func DecodeRFC3339(d Decoder, x interface{}) (interface{}, error) {
-	s := x.(string)
-	var result, err = time.Parse(time.RFC3339Nano, s)
-	return result, err
+	s, err := stringRep("Time", x)
+	if err != nil {
+		return nil, err
+	}
+	result, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return nil, newSyntaxError("Bad time", s, err)
+	}
+	return result, nil
 }
 
 // DecodeTime decodes a time value represended as millis since 1970.
 func DecodeTime(d Decoder, x interface{}) (interface{}, error) {
-	s := x.(string)
-	var millis, _ = strconv.ParseInt(s, 10, 64)
+	s, err := stringRep("Time", x)
+	if err != nil {
+		return nil, err
+	}
+	millis, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return nil, newSyntaxError("Bad time", s, err)
+	}
 	seconds := millis / 1000
 	remainder_millis := millis - (seconds * 1000)
 	nanos := remainder_millis * 1000000
@@ -128,32 +161,47 @@ func DecodeTime(d Decoder, x interface{}) (interface{}, error) {
 
 // DecodeBoolean decodes a transit boolean into a Go bool.
 func DecodeBoolean(d Decoder, x interface{}) (interface{}, error) {
-	s := x.(string)
+	s, err := stringRep("Boolean", x)
+	if err != nil {
+		return nil, err
+	}
 	if s == "t" {
 		return true, nil
 	} else if s == "f" {
 		return false, nil
 	} else {
-		return nil, &TransitError{Message: "Unknown boolean value."}
+		return nil, newSyntaxError("Unknown boolean value.", s, nil)
 	}
 }
 
 // DecodeBigInteger decodes a transit big integer into a Go big.Int.
 func DecodeBigInteger(d Decoder, x interface{}) (interface{}, error) {
-	s := x.(string)
+	s, err := stringRep("Big integer", x)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.limits.checkDigits(len(s)); err != nil {
+		return nil, err
+	}
 	result := new(big.Int)
 	_, good := result.SetString(s, 10)
 	if !good {
-		return nil, &TransitError{Message: "Unable to part big integer: " + s}
+		return nil, newSyntaxError("Unable to parse big integer", s, nil)
 	}
 	return result, nil
 }
 
 // DecodeInteger decodes a transit integer into a plain Go int64
 func DecodeInteger(d Decoder, x interface{}) (interface{}, error) {
-	s := x.(string)
+	s, err := stringRep("Integer", x)
+	if err != nil {
+		return nil, err
+	}
 	result, err := strconv.ParseInt(s, 10, 64)
-	return result, err
+	if err != nil {
+		return nil, newSyntaxError("Bad integer", s, err)
+	}
+	return result, nil
 }
 
 func newRational(a, b *big.Int) *big.Rat {
@@ -165,7 +213,7 @@ func newRational(a, b *big.Int) *big.Rat {
 func toBigInt(x interface{}) (*big.Int, error) {
 	switch v := x.(type) {
 	default:
-		return nil, NewTransitError("Not a numeric value", v)
+		return nil, newSyntaxError("Not a numeric value", v, nil)
 	case *big.Int:
 		return v, nil
 	case int64:
@@ -175,15 +223,15 @@ func toBigInt(x interface{}) (*big.Int, error) {
 
 // DecodeRatio decodes a transit ratio into a Go big.Rat.
 func DecodeRatio(d Decoder, x interface{}) (interface{}, error) {
-	tagged := x.(TaggedValue)
+	tagged, _ := x.(TaggedValue)
 	if !IsGenericArray(tagged.Value) {
-		return nil, NewTransitError("Ratio contents are not an array.", tagged)
+		return nil, newSyntaxError("Ratio contents are not an array.", tagged, nil)
 	}
 
 	values := (tagged.Value).([]interface{})
 
 	if len(values) != 2 {
-		return nil, NewTransitError("Ratio contents does not contain 2 elements.", tagged)
+		return nil, newSyntaxError("Ratio contents does not contain 2 elements.", tagged, nil)
 	}
 
 	a, err := toBigInt(values[0])
@@ -198,26 +246,53 @@ func DecodeRatio(d Decoder, x interface{}) (interface{}, error) {
 		return nil, err
 	}
 
+	if b.Sign() == 0 {
+		return nil, newSyntaxError("Ratio has a zero denominator.", tagged, nil)
+	}
+
 	result := newRational(a, b)
 	return *result, nil
 }
 
 // DecodeRune decodes a transit char.
 func DecodeRune(d Decoder, x interface{}) (interface{}, error) {
-	s := x.(string)
+	s, err := stringRep("Char", x)
+	if err != nil {
+		return nil, err
+	}
+	if len(s) == 0 {
+		return nil, newSyntaxError("Empty char", s, nil)
+	}
 	return rune(s[0]), nil
 }
 
 // DecodeFloat decodes the value into a float.
 func DecodeFloat(d Decoder, x interface{}) (interface{}, error) {
-	s := x.(string)
-	return strconv.ParseFloat(s, 64)
+	s, err := stringRep("Float", x)
+	if err != nil {
+		return nil, err
+	}
+	result, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil, newSyntaxError("Bad float", s, err)
+	}
+	return result, nil
 }
 
 // DecodeDecimal decodes a transit big decimal into decimal.Decimal.
 func DecodeDecimal(d Decoder, x interface{}) (interface{}, error) {
-	s := x.(string)
-	return decimal.NewFromString((s))
+	s, err := stringRep("Decimal", x)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.limits.checkDigits(len(s)); err != nil {
+		return nil, err
+	}
+	result, err := decimal.NewFromString((s))
+	if err != nil {
+		return nil, newSyntaxError("Bad decimal", s, err)
+	}
+	return result, nil
 }
 
 // DecodeRatio decodes a transit null/nil.
@@ -228,42 +303,64 @@ func DecodeNil(d Decoder, x interface{}) (interface{}, error) {
 // DecodeRatio decodes a transit base64 encoded byte array into a
 // Go byte array.
 func DecodeByte(d Decoder, x interface{}) (interface{}, error) {
-	s := x.(string)
-	return base64.StdEncoding.DecodeString(s)
+	s, err := stringRep("Bytes", x)
+	if err != nil {
+		return nil, err
+	}
+	result, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, newSyntaxError("Bad base64 bytes", s, err)
+	}
+	return result, nil
 }
 
 // DecodeLink decodes a transit link into an instance of Link.
 func DecodeLink(d Decoder, x interface{}) (interface{}, error) {
-	tv := x.(TaggedValue)
-	v := tv.Value.(map[interface{}]interface{})
+	tv, _ := x.(TaggedValue)
+	v, ok := tv.Value.(map[interface{}]interface{})
+	if !ok {
+		return nil, newSyntaxError("Link contents are not a map.", tv, nil)
+	}
 	l := NewLink()
-	l.Href = v["href"].(*TUri)
-	l.Name = v["name"].(string)
-	l.Rel = v["rel"].(string)
-	l.Prompt = v["prompt"].(string)
-	l.Render = v["render"].(string)
+	l.Href, _ = v["href"].(*TUri)
+	l.Name, _ = v["name"].(string)
+	l.Rel, _ = v["rel"].(string)
+	l.Prompt, _ = v["prompt"].(string)
+	l.Render, _ = v["render"].(string)
+	if l.Href == nil || l.Rel == "" {
+		return nil, newSyntaxError("Link must have an href and a rel.", tv, nil)
+	}
 	return l, nil
 }
 
 // DecodeURI decodes a transit URI into an instance of TUri.
 func DecodeURI(d Decoder, x interface{}) (interface{}, error) {
-	s := x.(string)
+	s, err := stringRep("URI", x)
+	if err != nil {
+		return nil, err
+	}
 	return NewTUri(s), nil
 }
 
 // DecodeUUID decodes a transit UUID into an instance of net/UUID
 func DecodeUUID(d Decoder, x interface{}) (interface{}, error) {
-	s := x.(string)
+	s, err := stringRep("UUID", x)
+	if err != nil {
+		return nil, err
+	}
 	var u = uuid.Parse(s)
 	if u == nil {
-		return nil, &TransitError{Message: "Unable to parse uuid [" + s + "]"}
+		return nil, newSyntaxError("Unable to parse uuid", s, nil)
 	}
 	return u, nil
 }
 
 // DecodeSpecialNumber decodes NaN, INF and -INF into their Go equivalents.
 func DecodeSpecialNumber(d Decoder, x interface{}) (interface{}, error) {
-	tag := x.(string)
+	tag, err := stringRep("Special number", x)
+	if err != nil {
+		return nil, err
+	}
 	if tag == "NaN" {
 		return math.NaN(), nil
 	} else if tag == "INF" {
@@ -271,6 +368,6 @@ func DecodeSpecialNumber(d Decoder, x interface{}) (interface{}, error) {
 	} else if tag == "-INF" {
 		return math.Inf(-1), nil
 	} else {
-		return nil, &TransitError{Message: "Bad special number:"}
+		return nil, newSyntaxError("Bad special number", tag, nil)
 	}
 }
@@ -23,7 +23,6 @@ import (
 	"fmt"
 	"github.com/pborman/uuid"
 	"github.com/shopspring/decimal"
-	"log"
 	"math"
 	"math/big"
 	"net/url"
@@ -78,8 +77,7 @@ func (ie PointerEncoder) IsStringable(v reflect.Value) bool {
 }
 
 func (ie PointerEncoder) Encode(e Encoder, v reflect.Value, asKey bool) error {
-	//log.Println("*** Defer pointer to:", v.Elem())
-	return e.EncodeInterface(v.Elem().Interface(), asKey)
+	return e.encodeElement(v.Elem(), asKey)
 }
 
 type UuidEncoder struct{}
@@ -141,7 +139,10 @@ func (ie FloatEncoder) IsStringable(v reflect.Value) bool {
 }
 
 func (ie FloatEncoder) Encode(e Encoder, v reflect.Value, asKey bool) error {
-	f := v.Float()
+	return encodeFloat(e, v.Float(), asKey)
+}
+
+func encodeFloat(e Encoder, f float64, asKey bool) error {
 	if math.IsNaN(f) {
 		return e.emitter.EmitString("~zNaN", asKey)
 	} else if math.IsInf(f, 1) {
@@ -211,15 +212,25 @@ func (ie BigRatEncoder) IsStringable(v reflect.Value) bool {
 func (ie BigRatEncoder) Encode(e Encoder, v reflect.Value, asKey bool) error {
 	r := v.Interface().(big.Rat)
 
-	e.emitter.EmitStartArray()
-	e.emitter.EmitTag("ratio")
-	e.emitter.EmitArraySeparator()
+	if err := e.emitStartTagged("ratio"); err != nil {
+		return err
+	}
 
-	e.emitter.EmitStartArray()
-	e.Encode(r.Num())
-	e.emitter.EmitArraySeparator()
-	e.Encode(r.Denom())
-	e.emitter.EmitEndArray()
+	if err := e.emitter.EmitStartArray(); err != nil {
+		return err
+	}
+	if err := e.EncodeInterface(r.Num(), false); err != nil {
+		return err
+	}
+	if err := e.emitter.EmitArraySeparator(); err != nil {
+		return err
+	}
+	if err := e.EncodeInterface(r.Denom(), false); err != nil {
+		return err
+	}
+	if err := e.emitter.EmitEndArray(); err != nil {
+		return err
+	}
 
 	return e.emitter.EmitEndArray()
 }
@@ -295,6 +306,10 @@ func (ie StringEncoder) IsStringable(v reflect.Value) bool {
 }
 
 func (ie StringEncoder) needsEscape(s string) bool {
+	return needsEscape(s)
+}
+
+func needsEscape(s string) bool {
 	if len(s) == 0 {
 		return false
 	}
@@ -305,8 +320,11 @@ func (ie StringEncoder) needsEscape(s string) bool {
 }
 
 func (ie StringEncoder) Encode(e Encoder, v reflect.Value, asKey bool) error {
-	s := v.String()
-	if ie.needsEscape(s) {
+	return encodeString(e, v.String(), asKey)
+}
+
+func encodeString(e Encoder, s string, asKey bool) error {
+	if needsEscape(s) {
 		s = "~" + s
 	}
 	return e.emitter.EmitString(s, asKey)
@@ -339,7 +357,7 @@ func (ie TUriEncoder) IsStringable(v reflect.Value) bool {
 }
 
 func (ie TUriEncoder) Encode(e Encoder, v reflect.Value, asKey bool) error {
-	u := v.Interface().(*TUri)
+	u := reflect.Indirect(v).Interface().(TUri)
 	return e.emitter.EmitString(fmt.Sprintf("~r%s", u.Value), asKey)
 }
 
@@ -354,7 +372,8 @@ func (ie ErrorEncoder) IsStringable(v reflect.Value) bool {
 }
 
 func (ie ErrorEncoder) Encode(e Encoder, v reflect.Value, asKey bool) error {
-	return NewTransitError("Dont know how to encode value", v)
+	msg := fmt.Sprintf("Dont know how to encode value of type %v", v.Type())
+	return &TransitError{Kind: KindUnknownType, Message: msg, Source: v.Interface()}
 }
 
 type ArrayEncoder struct{}
@@ -368,17 +387,20 @@ func (ie ArrayEncoder) IsStringable(v reflect.Value) bool {
 }
 
 func (ie ArrayEncoder) Encode(e Encoder, v reflect.Value, asKey bool) error {
-	e.emitter.EmitStartArray()
+	if err := e.emitter.EmitStartArray(); err != nil {
+		return err
+	}
 
 	l := v.Len()
 	for i := 0; i < l; i++ {
 		if i > 0 {
-			e.emitter.EmitArraySeparator()
+			if err := e.emitter.EmitArraySeparator(); err != nil {
+				return err
+			}
 		}
-		element := v.Index(i)
-		err := e.EncodeInterface(element.Interface(), asKey)
+		err := e.encodeElement(v.Index(i), asKey)
 		if err != nil {
-			return err
+			return withPath(err, i)
 		}
 	}
 
@@ -398,121 +420,107 @@ func (me MapEncoder) IsStringable(v reflect.Value) bool {
 }
 
 func (me MapEncoder) Encode(e Encoder, v reflect.Value, asKey bool) error {
-	keys := KeyValues(v)
-
-	if !me.allStringable(e, keys) {
+	if !me.allStringable(e, v) {
 		return me.encodeCompositeMap(e, v)
-	} else if me.verbose {
-		return me.encodeVerboseMap(e, v)
-	} else {
-		return me.encodeNormalMap(e, v)
 	}
-}
 
-func (me MapEncoder) allStringable(e Encoder, keys []reflect.Value) bool {
-	for _, key := range keys {
-		valueEncoder := e.ValueEncoderFor(reflect.ValueOf(key.Interface()))
-		if !valueEncoder.IsStringable(key) {
-			return false
-		}
+	if err := e.emitStartMap(me.verbose); err != nil {
+		return err
 	}
-	return true
-}
 
-func (me MapEncoder) encodeCompositeMap(e Encoder, v reflect.Value) error {
-	e.emitter.EmitStartArray()
-
-	e.emitter.EmitTag("cmap")
-	e.emitter.EmitArraySeparator()
-
-	e.emitter.EmitStartArray()
+	// Reuse the same key and value for every entry, rather than
+	// have the iterator allocate new ones.
+	key := reflect.New(v.Type().Key()).Elem()
+	value := reflect.New(v.Type().Elem()).Elem()
 
-	keys := KeyValues(v)
+	i := 0
+	iter := v.MapRange()
+	for iter.Next() {
+		key.SetIterKey(iter)
+		value.SetIterValue(iter)
 
-	for i, key := range keys {
-		if i != 0 {
-			e.emitter.EmitArraySeparator()
+		if err := e.emitBeforeKey(me.verbose, i); err != nil {
+			return err
 		}
 
-		err := e.EncodeValue(key, false)
+		if err := e.encodeElement(key, true); err != nil {
+			return withPath(err, key.Interface())
+		}
 
-		if err != nil {
+		if err := e.emitBeforeValue(me.verbose); err != nil {
 			return err
 		}
 
-		e.emitter.EmitArraySeparator()
-
-		value := GetMapElement(v, key)
-		err = e.EncodeValue(value, false)
-
-		if err != nil {
-			return err
+		if err := e.encodeElement(value, false); err != nil {
+			return withPath(err, key.Interface())
 		}
+		i++
 	}
 
-	e.emitter.EmitEndArray()
-	return e.emitter.EmitEndArray()
+	return e.emitEndMap(me.verbose)
 }
 
-func (me MapEncoder) encodeNormalMap(e Encoder, v reflect.Value) error {
-	//l := v.Len()
-	e.emitter.EmitStartArray()
-
-	e.emitter.EmitString("^ ", false)
-
-	keys := KeyValues(v)
-
-	for _, key := range keys {
-		e.emitter.EmitArraySeparator()
-
-		err := e.EncodeValue(key, true)
+func (me MapEncoder) allStringable(e Encoder, v reflect.Value) bool {
+	keyType := v.Type().Key()
 
-		if err != nil {
-			return err
+	if keyType.Kind() != reflect.Interface {
+		keyEncoder := e.planFor(keyType).encoder
+		iter := v.MapRange()
+		for iter.Next() {
+			if !keyEncoder.IsStringable(iter.Key()) {
+				return false
+			}
 		}
+		return true
+	}
 
-		e.emitter.EmitArraySeparator()
-
-		value := GetMapElement(v, key)
-
-		err = e.EncodeValue(value, false)
-
-		if err != nil {
-			return err
+	iter := v.MapRange()
+	for iter.Next() {
+		if !e.isStringable(iter.Key().Interface()) {
+			return false
 		}
 	}
-
-	return e.emitter.EmitEndArray()
+	return true
 }
 
-func (me MapEncoder) encodeVerboseMap(e Encoder, v reflect.Value) error {
-	e.emitter.EmitStartMap()
+func (me MapEncoder) encodeCompositeMap(e Encoder, v reflect.Value) error {
+	if err := e.emitStartTagged("cmap"); err != nil {
+		return err
+	}
+
+	if err := e.emitter.EmitStartArray(); err != nil {
+		return err
+	}
 
-	keys := KeyValues(v)
+	i := 0
+	iter := v.MapRange()
+	for iter.Next() {
+		key := iter.Key()
 
-	for i, key := range keys {
 		if i != 0 {
-			e.emitter.EmitMapSeparator()
+			if err := e.emitter.EmitArraySeparator(); err != nil {
+				return err
+			}
 		}
 
-		err := e.EncodeValue(key, true)
+		if err := e.encodeElement(key, false); err != nil {
+			return withPath(err, i)
+		}
 
-		if err != nil {
+		if err := e.emitter.EmitArraySeparator(); err != nil {
 			return err
 		}
 
-		e.emitter.EmitKeySeparator()
-
-		value := GetMapElement(v, key)
-
-		err = e.EncodeValue(value, false)
-
-		if err != nil {
-			return err
+		if err := e.encodeElement(iter.Value(), false); err != nil {
+			return withPath(err, key.Interface())
 		}
+		i++
 	}
 
-	return e.emitter.EmitEndMap()
+	if err := e.emitter.EmitEndArray(); err != nil {
+		return err
+	}
+	return e.emitter.EmitEndArray()
 }
 
 type TaggedValueEncoder struct{}
@@ -528,10 +536,12 @@ func (ie TaggedValueEncoder) IsStringable(v reflect.Value) bool {
 func (ie TaggedValueEncoder) Encode(e Encoder, v reflect.Value, asKey bool) error {
 	t := v.Interface().(TaggedValue)
 
-	e.emitter.EmitStartArray()
-	e.emitter.EmitTag(string(t.Tag))
-	e.emitter.EmitArraySeparator()
-	e.EncodeInterface(t.Value, asKey)
+	if err := e.emitStartTagged(string(t.Tag)); err != nil {
+		return err
+	}
+	if err := e.EncodeInterface(t.Value, asKey); err != nil {
+		return err
+	}
 	return e.emitter.EmitEndArray()
 }
 
@@ -548,26 +558,29 @@ func (ie SetEncoder) IsStringable(v reflect.Value) bool {
 func (ie SetEncoder) Encode(e Encoder, v reflect.Value, asKey bool) error {
 	s := v.Interface().(Set)
 
-	//log.Println("*** Encode set:", v)
-
-	//l := v.Len()
-	e.emitter.EmitStartArray()
-	e.emitter.EmitTag("set")
-	e.emitter.EmitArraySeparator()
+	if err := e.emitStartTagged("set"); err != nil {
+		return err
+	}
 
-	e.emitter.EmitStartArray()
+	if err := e.emitter.EmitStartArray(); err != nil {
+		return err
+	}
 
 	for i, element := range s.Contents {
 		if i != 0 {
-			e.emitter.EmitArraySeparator()
+			if err := e.emitter.EmitArraySeparator(); err != nil {
+				return err
+			}
 		}
 		err := e.EncodeInterface(element, asKey)
 		if err != nil {
-			return err
+			return withPath(err, i)
 		}
 	}
 
-	e.emitter.EmitEndArray()
+	if err := e.emitter.EmitEndArray(); err != nil {
+		return err
+	}
 
 	return e.emitter.EmitEndArray()
 }
@@ -585,27 +598,32 @@ func (ie ListEncoder) IsStringable(v reflect.Value) bool {
 func (ie ListEncoder) Encode(e Encoder, v reflect.Value, asKey bool) error {
 	lst := v.Interface().(*list.List)
 
-	e.emitter.EmitStartArray()
-	e.emitter.EmitTag("list")
-	e.emitter.EmitArraySeparator()
-	e.emitter.EmitStartArray()
+	if err := e.emitStartTagged("list"); err != nil {
+		return err
+	}
 
-	first := true
+	if err := e.emitter.EmitStartArray(); err != nil {
+		return err
+	}
+
+	i := 0
 	for element := lst.Front(); element != nil; element = element.Next() {
-		if first {
-			first = false
-		} else {
-			e.emitter.EmitArraySeparator()
+		if i > 0 {
+			if err := e.emitter.EmitArraySeparator(); err != nil {
+				return err
+			}
 		}
 
 		err := e.EncodeInterface(element.Value, asKey)
 		if err != nil {
-			log.Println("ERROR", err)
-			return err
+			return withPath(err, i)
 		}
+		i++
 	}
 
-	e.emitter.EmitEndArray()
+	if err := e.emitter.EmitEndArray(); err != nil {
+		return err
+	}
 	return e.emitter.EmitEndArray()
 }
 
@@ -620,33 +638,41 @@ func (ie CMapEncoder) IsStringable(v reflect.Value) bool {
 }
 
 func (ie CMapEncoder) Encode(e Encoder, v reflect.Value, asKey bool) error {
-	cmap := v.Interface().(*CMap)
+	cmap := reflect.Indirect(v).Interface().(CMap)
 
-	//l := v.Len()
-	e.emitter.EmitStartArray()
-	e.emitter.EmitTag("cmap")
-	e.emitter.EmitArraySeparator()
-	e.emitter.EmitStartArray()
+	if err := e.emitStartTagged("cmap"); err != nil {
+		return err
+	}
+
+	if err := e.emitter.EmitStartArray(); err != nil {
+		return err
+	}
 
 	for i, entry := range cmap.Entries {
 		if i != 0 {
-			e.emitter.EmitArraySeparator()
+			if err := e.emitter.EmitArraySeparator(); err != nil {
+				return err
+			}
 		}
 
 		err := e.EncodeInterface(entry.Key, false)
 		if err != nil {
-			return err
+			return withPath(err, i)
 		}
 
-		e.emitter.EmitArraySeparator()
+		if err := e.emitter.EmitArraySeparator(); err != nil {
+			return err
+		}
 
 		err = e.EncodeInterface(entry.Value, false)
 		if err != nil {
-			return err
+			return withPath(err, entry.Key)
 		}
 	}
 
-	e.emitter.EmitEndArray()
+	if err := e.emitter.EmitEndArray(); err != nil {
+		return err
+	}
 	return e.emitter.EmitEndArray()
 }
 
@@ -661,11 +687,11 @@ func (ie LinkEncoder) IsStringable(v reflect.Value) bool {
 }
 
 func (ie LinkEncoder) Encode(e Encoder, v reflect.Value, asKey bool) error {
-	link := v.Interface().(*Link)
+	link := v.Interface().(Link)
 
-	e.emitter.EmitStartArray()
-	e.emitter.EmitTag("link")
-	e.emitter.EmitArraySeparator()
+	if err := e.emitStartTagged("link"); err != nil {
+		return err
+	}
 
 	m := map[string]interface{}{
 		"href":   link.Href,
@@ -675,6 +701,8 @@ func (ie LinkEncoder) Encode(e Encoder, v reflect.Value, asKey bool) error {
 		"render": link.Render,
 	}
 
-	e.Encode(m)
+	if err := e.EncodeInterface(m, false); err != nil {
+		return err
+	}
 	return e.emitter.EmitEndArray()
 }
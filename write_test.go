@@ -0,0 +1,499 @@
+// Copyright 2016 Russ Olsen. All Rights Reserved.
+//
+// This code is a Go port of the Java version created and maintained by Cognitect, therefore:
+//
+// Copyright 2014 Cognitect. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS-IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transit
+
+import (
+	"bytes"
+	"container/list"
+	"encoding/json"
+	"errors"
+	"github.com/pborman/uuid"
+	"github.com/shopspring/decimal"
+	"math/big"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// failingWriter accepts limit bytes and then fails every write.
+type failingWriter struct {
+	limit         int
+	written       int
+	failed        bool
+	writesAfterEr int
+}
+
+var errDiskFull = errors.New("disk full")
+
+func (fw *failingWriter) Write(p []byte) (int, error) {
+	if fw.failed {
+		fw.writesAfterEr++
+	}
+	n := len(p)
+	if fw.written+n > fw.limit {
+		n = fw.limit - fw.written
+	}
+	fw.written += n
+	if n < len(p) {
+		fw.failed = true
+		return n, errDiskFull
+	}
+	return n, nil
+}
+
+func writeTestValue() interface{} {
+	lst := list.New()
+	lst.PushBack(Keyword("listed"))
+
+	link := NewLink()
+	link.Href = NewTUri("http://example.com")
+	link.Rel = "self"
+
+	return []interface{}{
+		1, "two", Keyword("three"),
+		map[Keyword]interface{}{Keyword("user"): []interface{}{Symbol("tags"), 4.5}},
+		MakeSet(1, 2, 3),
+		lst,
+		*big.NewRat(1, 3),
+		NewCMap().Append([]interface{}{1}, "composite"),
+		TaggedValue{TagId("point"), []interface{}{1, 2}},
+		link,
+	}
+}
+
+func TestWriteErrors(t *testing.T) {
+	value := writeTestValue()
+
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf, false).Encode(value); err != nil {
+		t.Fatalf("Unexpected error encoding test value: %v", err)
+	}
+
+	for limit := 0; limit < buf.Len(); limit++ {
+		w := &failingWriter{limit: limit}
+		e := NewEncoder(w, false)
+
+		err := e.Encode(value)
+
+		if !errors.Is(err, KindWrite) || !errors.Is(err, errDiskFull) {
+			t.Errorf("Expected a write error writing %v bytes, got: %v", limit, err)
+			continue
+		}
+
+		if written := err.(*TransitError).Written; written != int64(limit) {
+			t.Errorf("Expected the error to report %v bytes written, got %v", limit, written)
+		}
+
+		if e.BytesWritten() != int64(limit) {
+			t.Errorf("Expected the encoder to report %v bytes written, got %v", limit, e.BytesWritten())
+		}
+
+		if w.writesAfterEr > 0 {
+			t.Errorf("Encoder kept writing after the first error (limit %v)", limit)
+		}
+	}
+}
+
+// TestWriteErrorKept checks that the write error the encoder keeps is
+// not changed by the paths added to the errors returned later.
+func TestWriteErrorKept(t *testing.T) {
+	e := NewEncoder(&failingWriter{limit: 0}, false)
+	value := []interface{}{[]interface{}{strings.Repeat("x", 2*bufferSize)}}
+
+	first := e.Encode(value)
+	VerifyError(t, first, KindWrite, "[0 0]")
+
+	for i := 0; i < 2; i++ {
+		VerifyError(t, e.Encode(value), KindWrite, "[]")
+	}
+	VerifyError(t, first, KindWrite, "[0 0]")
+
+	if e.Encode(value) == e.Encode(value) {
+		t.Errorf("Expected each Encode to return its own copy of the write error")
+	}
+}
+
+func TestWriteQuotedStrings(t *testing.T) {
+	values := []string{
+		"", "plain", `"quoted"`, `back\slash`, "tab\tnew\nline\rreturn",
+		"\x00\x01\x1f\x7f", "café", "  ", "invalid \xff utf8", "<html>&amp;",
+	}
+
+	for _, s := range values {
+		quoted := appendQuoted(nil, s)
+
+		var expected string
+		bytes, _ := json.Marshal(s)
+		json.Unmarshal(bytes, &expected)
+
+		var actual string
+		if err := json.Unmarshal(quoted, &actual); err != nil {
+			t.Errorf("Quoting %q produced invalid JSON %s: %v", s, quoted, err)
+		} else if actual != expected {
+			t.Errorf("Quoting %q produced %s which reads back as %q", s, quoted, actual)
+		}
+	}
+}
+
+func TestWriteBuffering(t *testing.T) {
+	var wc writeCounter
+	e := NewEncoder(&wc, false)
+	e.SetAutoFlush(false)
+
+	for i := 0; i < 10; i++ {
+		if err := e.Encode(smallMessage(i)); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	if wc.writes != 0 {
+		t.Errorf("Expected no writes before Flush, got %v", wc.writes)
+	}
+
+	if err := e.Flush(); err != nil {
+		t.Fatalf("Unexpected error flushing: %v", err)
+	}
+
+	if wc.writes != 1 {
+		t.Errorf("Expected a single write after Flush, got %v", wc.writes)
+	}
+}
+
+func TestWriteAutoFlush(t *testing.T) {
+	var wc writeCounter
+	e := NewEncoder(&wc, false)
+
+	for i := 1; i <= 10; i++ {
+		if err := e.Encode(smallMessage(i)); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if wc.writes != i {
+			t.Fatalf("Expected one write per Encode, got %v after %v values", wc.writes, i)
+		}
+	}
+
+	if err := e.Flush(); err != nil {
+		t.Fatalf("Unexpected error flushing: %v", err)
+	}
+
+	if wc.writes != 10 {
+		t.Errorf("Expected Flush to have nothing left to write, got %v writes", wc.writes)
+	}
+}
+
+func TestWriteReset(t *testing.T) {
+	e := NewEncoder(&failingWriter{limit: 0}, false)
+
+	if err := e.Encode(Keyword("abcd")); err == nil {
+		t.Fatal("Expected a write error")
+	}
+
+	var buf bytes.Buffer
+	e.Reset(&buf)
+
+	if err := e.Encode([]interface{}{Keyword("abcd"), Keyword("abcd")}); err != nil {
+		t.Fatalf("Unexpected error after Reset: %v", err)
+	}
+
+	expected := `["~:abcd","^0"]`
+	if buf.String() != expected {
+		t.Errorf("Expected %v after Reset, got %v", expected, buf.String())
+	}
+}
+
+func TestWriteCachePerValue(t *testing.T) {
+	var buf bytes.Buffer
+	e := NewEncoder(&buf, false)
+
+	value := []interface{}{Keyword("abcd"), Keyword("abcd")}
+
+	for i := 0; i < 2; i++ {
+		if err := e.Encode(value); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	expected := `["~:abcd","^0"]["~:abcd","^0"]`
+	if buf.String() != expected {
+		t.Errorf("Expected every value to start with an empty cache: %v, got %v", expected, buf.String())
+	}
+}
+
+type shoutingEncoder struct{}
+
+func (se shoutingEncoder) IsStringable(v reflect.Value) bool {
+	return true
+}
+
+func (se shoutingEncoder) Encode(e Encoder, v reflect.Value, asKey bool) error {
+	return e.emitter.EmitString(strings.ToUpper(v.String()), asKey)
+}
+
+func TestWriteCustomHandlerOverridesPlans(t *testing.T) {
+	var buf bytes.Buffer
+	e := NewEncoder(&buf, false)
+
+	value := []interface{}{"abc", map[string]interface{}{"def": "ghi"}, []string{"jkl"}}
+
+	// Compile the plans before adding the handler.
+	if err := e.Encode(value); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	buf.Reset()
+
+	e.AddHandler(reflect.TypeOf(""), shoutingEncoder{})
+
+	if err := e.Encode(value); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := `["ABC",["^ ","DEF","GHI"],["JKL"]]`
+	if buf.String() != expected {
+		t.Errorf("Expected %v, got %v", expected, buf.String())
+	}
+}
+
+func TestWriteFastPathsKept(t *testing.T) {
+	var buf bytes.Buffer
+	e := NewEncoder(&buf, false)
+
+	type shout string
+	e.AddHandler(reflect.TypeOf(time.Duration(0)), shoutingEncoder{})
+	e.AddHandler(reflect.TypeOf(shout("")), shoutingEncoder{})
+
+	if !e.plans.fast {
+		t.Fatal("Expected handlers for other named types to keep the fast paths")
+	}
+
+	if err := e.Encode([]interface{}{"abc", shout("def")}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := `["abc","DEF"]`
+	if buf.String() != expected {
+		t.Errorf("Expected %v, got %v", expected, buf.String())
+	}
+
+	e.AddHandler(reflect.TypeOf(""), shoutingEncoder{})
+	if e.plans.fast {
+		t.Error("Expected a string handler to turn off the fast paths")
+	}
+}
+
+func TestWriteCycles(t *testing.T) {
+	m := map[string]interface{}{}
+	m["self"] = m
+
+	a := []interface{}{1, nil}
+	a[1] = a
+
+	var p interface{}
+	p = &p
+
+	g := map[interface{}]interface{}{}
+	g[Keyword("next")] = []interface{}{g}
+
+	for _, value := range []interface{}{m, a, &p, g} {
+		_, err := EncodeToString(value, false)
+		if !errors.Is(err, KindCycle) {
+			t.Errorf("Expected a cycle error, got %v", err)
+		}
+	}
+
+	_, err := EncodeToString(m, false)
+	path := err.(*TransitError).Path
+	if len(path) == 0 || path[0] != "self" {
+		t.Errorf("Expected the path to lead through self, got %v", path)
+	}
+}
+
+func TestWriteSharedValues(t *testing.T) {
+	shared := []interface{}{1, 2}
+
+	// Deep enough that the encoder is looking for cycles.
+	var value interface{} = []interface{}{shared, shared}
+	for i := 0; i < 2*cycleCheckDepth; i++ {
+		value = []interface{}{value}
+	}
+
+	if _, err := EncodeToString(value, false); err != nil {
+		t.Errorf("Values that appear twice are not cycles: %v", err)
+	}
+}
+
+func TestWriteMaxDepth(t *testing.T) {
+	value := []interface{}{map[string]interface{}{"a": []int{1}}}
+
+	var buf bytes.Buffer
+	e := NewEncoder(&buf, false)
+	e.SetMaxDepth(3)
+	if err := e.Encode(value); err != nil {
+		t.Fatalf("Unexpected error at the maximum depth: %v", err)
+	}
+
+	e.SetMaxDepth(2)
+	err := e.Encode(value)
+	VerifyError(t, err, KindLimit, "[0 a]")
+
+	e.SetMaxDepth(0)
+	if err := e.Encode(value); err != nil {
+		t.Errorf("Unexpected error with no maximum depth: %v", err)
+	}
+}
+
+func TestWriteNils(t *testing.T) {
+	var nilInt *int
+
+	values := []interface{}{
+		(*string)(nil), (*bool)(nil), (*float64)(nil), (*float32)(nil),
+		nilInt, (*int64)(nil), (*uint8)(nil), (*uint64)(nil), &nilInt,
+		(*rune)(nil), (*time.Time)(nil), uuid.UUID(nil), (*decimal.Decimal)(nil),
+		(*big.Int)(nil), (*big.Rat)(nil), (*big.Float)(nil),
+		(*Keyword)(nil), (*Symbol)(nil), (*url.URL)(nil), (*TUri)(nil),
+		(*list.List)(nil), (*CMap)(nil), (*Set)(nil), (*Link)(nil), (*TaggedValue)(nil),
+		[]int(nil), []interface{}(nil), map[string]int(nil),
+		map[interface{}]interface{}(nil), map[string]interface{}(nil),
+		[]*big.Int{nil}, map[string]*url.URL{"u": nil},
+	}
+
+	for _, value := range values {
+		var buf bytes.Buffer
+		if err := NewEncoder(&buf, false).Encode([]interface{}{value}); err != nil {
+			t.Errorf("Unexpected error encoding nil %T: %v", value, err)
+			continue
+		}
+
+		expected := `[null]`
+		switch value.(type) {
+		case []*big.Int:
+			expected = `[[null]]`
+		case map[string]*url.URL:
+			expected = `[["^ ","u",null]]`
+		}
+		if buf.String() != expected {
+			t.Errorf("Expected nil %T to encode as %v, got %v", value, expected, buf.String())
+		}
+
+		buf.Reset()
+		if err := NewEncoder(&buf, false).Encode(value); err != nil {
+			t.Errorf("Unexpected error encoding nil %T at the top level: %v", value, err)
+		}
+	}
+}
+
+func TestWriteNilKeysAndTopLevel(t *testing.T) {
+	tests := []struct {
+		value    interface{}
+		expected string
+	}{
+		{nil, `["~#'",null]`},
+		{(*big.Int)(nil), `["~#'",null]`},
+		{[]int(nil), `["~#'",null]`},
+		{map[interface{}]interface{}{(*big.Int)(nil): 1}, `["^ ","~_",1]`},
+	}
+
+	for _, test := range tests {
+		actual, err := EncodeToString(test.value, false)
+		if err != nil {
+			t.Errorf("Unexpected error encoding %#v: %v", test.value, err)
+		} else if actual != test.expected {
+			t.Errorf("Expected %#v to encode as %v, got %v", test.value, test.expected, actual)
+		}
+	}
+}
+
+func TestWriteNilAsEmpty(t *testing.T) {
+	value := []interface{}{
+		[]int(nil), []interface{}(nil), map[string]int(nil),
+		map[interface{}]interface{}(nil), map[string]interface{}(nil),
+		(*[]int)(nil),
+	}
+
+	var buf bytes.Buffer
+	e := NewEncoder(&buf, false)
+	e.SetNilAsEmpty(true)
+	if err := e.Encode(value); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := `[[],[],["^ "],["^ "],["^ "],null]`
+	if buf.String() != expected {
+		t.Errorf("Expected %v, got %v", expected, buf.String())
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	value := writeTestValue()
+
+	expected, err := EncodeToString(value, false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		actual, err := Marshal(value)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		// Maps come out in no particular order, so compare what
+		// the two decode to.
+		a, _ := DecodeBytes(actual)
+		b, _ := DecodeFromString(expected)
+		if len(actual) != len(expected) || !reflect.DeepEqual(a, b) {
+			t.Errorf("Expected %v, got %s", expected, actual)
+		}
+	}
+}
+
+func TestMarshalAppend(t *testing.T) {
+	dst := []byte("prefix:")
+
+	b, err := MarshalAppend(dst, []interface{}{Keyword("abcd"), Keyword("abcd")})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if expected := `prefix:["~:abcd","^0"]`; string(b) != expected {
+		t.Errorf("Expected %v, got %s", expected, b)
+	}
+
+	// Output much bigger than the encoder's buffer all ends up in dst.
+	large := make([]interface{}, 5000)
+	for i := range large {
+		large[i] = i
+	}
+	b, err = MarshalAppend(nil, large)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if decoded, err := DecodeBytes(b); err != nil || len(decoded.([]interface{})) != len(large) {
+		t.Errorf("Expected all %v elements to be marshaled, got %v", len(large), err)
+	}
+
+	type unknown struct{}
+	b, err = MarshalAppend(dst, []interface{}{1, unknown{}})
+	if !errors.Is(err, KindUnknownType) {
+		t.Errorf("Expected an unknown type error, got %v", err)
+	}
+	if string(b) != "prefix:" {
+		t.Errorf("Expected dst back unchanged after an error, got %s", b)
+	}
+}